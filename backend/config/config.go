@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata" // Base de datos de zonas horarias embebida para contenedores sin tzdata
 
 	"github.com/joho/godotenv"
 )
@@ -17,6 +20,20 @@ type Config struct {
 	DBHost string
 	DBPort string
 	JwtKey string
+
+	PosAPIKey string // Clave con la que se autentican los TPV de las tiendas al enviar tickets
+
+	ReceiptClaimWindowDays    int // Antigüedad maxima en dias de un ticket reclamable
+	RedemptionTokenTTLSeconds int // Validez en segundos de los tokens QR de canje de promociones
+	PromotionRetentionDays    int // Dias que se conservan las promociones eliminadas antes de purgarlas
+	UsageVoidWindowMinutes    int // Minutos durante los que el personal puede anular un consumo de promocion
+	MaxImageBytes             int // Tamaño maximo en bytes de las imagenes subidas
+
+	MediaDir     string // Directorio local donde se guardan las imagenes
+	MediaBaseURL string // URL publica bajo la que se sirven las imagenes
+
+	BusinessTimeZone string         // Zona horaria del restaurante (IANA, ej. Europe/Madrid)
+	BusinessLocation *time.Location // Zona horaria cargada a partir de BusinessTimeZone
 }
 
 func LoadEnv() {
@@ -33,8 +50,48 @@ func LoadEnv() {
 		DBHost: os.Getenv("DB_HOST"),
 		DBPort: os.Getenv("DB_PORT"),
 		JwtKey: os.Getenv("JWT_KEY"),
+
+		PosAPIKey: os.Getenv("POS_API_KEY"),
+
+		ReceiptClaimWindowDays:    getEnvInt("RECEIPT_CLAIM_WINDOW_DAYS", 30),
+		RedemptionTokenTTLSeconds: getEnvInt("REDEMPTION_TOKEN_TTL_SECONDS", 120),
+		PromotionRetentionDays:    getEnvInt("PROMOTION_RETENTION_DAYS", 365),
+		UsageVoidWindowMinutes:    getEnvInt("USAGE_VOID_WINDOW_MINUTES", 30),
+		MaxImageBytes:             getEnvInt("MAX_IMAGE_BYTES", 5<<20),
+
+		MediaDir:     getEnv("MEDIA_DIR", "./media"),
+		MediaBaseURL: getEnv("MEDIA_BASE_URL", "/media"),
+
+		BusinessTimeZone: getEnv("BUSINESS_TIMEZONE", "Europe/Madrid"),
+	}
+
+	Vars.BusinessLocation, err = time.LoadLocation(Vars.BusinessTimeZone)
+	if err != nil {
+		log.Fatalf("Invalid value for BUSINESS_TIMEZONE: %v", err)
 	}
 
 	fmt.Println("Environments var imported")
 
 }
+
+// getEnv lee una variable de entorno y devuelve el valor por defecto si no existe
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvInt lee una variable de entorno numerica y devuelve el valor por defecto si no existe
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return parsed
+}
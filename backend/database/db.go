@@ -28,10 +28,74 @@ func ConnectDB() *gorm.DB {
 		log.Fatalf("Error connecting to database: %v", err)
 	}
 
+	migratePromotionDates(DB)
+	migratePromotionStatus(DB)
+
 	DB.AutoMigrate(
 		&models.User{},
 		&models.Promotion{},
+		&models.PromotionUsage{},
+		&models.PromotionRevision{},
+		&models.PromotionImage{},
+		&models.PromotionSchedule{},
+		&models.Segment{},
+		&models.PromotionSegment{},
+		&models.StampCard{},
+		&models.UserStampCard{},
+		&models.StampReward{},
+		&models.PointTransaction{},
+		&models.Challenge{},
+		&models.UserChallenge{},
+		&models.UserBadge{},
+		&models.PosTransaction{},
+		&models.ReceiptClaim{},
+		&models.FraudAlert{},
+		&models.CouponBatch{},
+		&models.CouponCode{},
+		&models.RedemptionToken{},
+		&models.Voucher{},
+		&models.Experiment{},
+		&models.ExperimentVariant{},
+		&models.ExperimentExposure{},
 	)
 
+	// Indice para la busqueda de texto completo en el listado de promociones
+	DB.Exec(`CREATE INDEX IF NOT EXISTS idx_promotions_search ON promotions
+		USING gin (to_tsvector('spanish', title || ' ' || coalesce(description, '')))`)
+
 	return DB
 }
+
+// migratePromotionDates convierte las fechas de las promociones guardadas como texto a columnas `date`
+func migratePromotionDates(db *gorm.DB) {
+	var dataType string
+	db.Raw(`SELECT data_type FROM information_schema.columns
+		WHERE table_name = 'promotions' AND column_name = 'start_date'`).Scan(&dataType)
+	if dataType == "" || dataType == "date" {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE promotions SET end_date = NULL WHERE end_date = ''`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`ALTER TABLE promotions ALTER COLUMN start_date TYPE date USING start_date::date`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`ALTER TABLE promotions ALTER COLUMN end_date TYPE date USING end_date::date`).Error
+	})
+	if err != nil {
+		log.Fatalf("Error migrating promotion dates: %v", err)
+	}
+}
+
+// migratePromotionStatus publica las promociones creadas antes de existir el ciclo de vida, que ya
+// eran visibles para los clientes, para que no pasen a borrador al crear la columna `status`
+func migratePromotionStatus(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.Promotion{}) || db.Migrator().HasColumn(&models.Promotion{}, "Status") {
+		return
+	}
+	if err := db.Exec(`ALTER TABLE promotions ADD COLUMN status varchar(20) NOT NULL DEFAULT 'published'`).Error; err != nil {
+		log.Fatalf("Error migrating promotion status: %v", err)
+	}
+}
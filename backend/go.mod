@@ -6,7 +6,9 @@ require (
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.28.0
+	golang.org/x/image v0.18.0
 	gorm.io/driver/postgres v1.5.9
 	gorm.io/gorm v1.25.12
 )
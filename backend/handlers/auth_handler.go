@@ -27,6 +27,7 @@ func (h *AuthHandler) RegisterNewUser(w http.ResponseWriter, r *http.Request) {
 		LastName    string `json:"last_name"`
 		BirthDate   string `json:"birth_date"`
 		Gender      string `json:"gender"`
+		StoreID     string `json:"store_id"`
 		Email       string `json:"email"`
 		Password    string `json:"password"`
 		PassConfirm string `json:"pass_confirm"`
@@ -44,6 +45,7 @@ func (h *AuthHandler) RegisterNewUser(w http.ResponseWriter, r *http.Request) {
 		LastName:  input.LastName,
 		BirthDate: input.BirthDate,
 		Gender:    input.Gender,
+		StoreID:   input.StoreID,
 		Email:     input.Email,
 		Password:  input.Password,
 	}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/models"
+	"fidelity-client-app/services"
+	"net/http"
+)
+
+type ChallengeHandler struct {
+	ChallengeService *services.ChallengeService
+}
+
+// CreateChallenge maneja la solicitud para crear un nuevo reto
+func (h *ChallengeHandler) CreateChallenge(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var challenge models.Challenge
+
+	// Decodificamos el JSON recibido en el cuerpo de la solicitud
+	if err := json.NewDecoder(r.Body).Decode(&challenge); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// Llamamos al servicio para crear el reto
+	if err := h.ChallengeService.CreateChallenge(&challenge); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Enviamos una respuesta 201 para indicar creacion exitosa
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(challenge)
+}
+
+// GetUserChallenges maneja la solicitud para obtener los retos disponibles, en curso y completados de un usuario
+func (h *ChallengeHandler) GetUserChallenges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := targetUserID(r)
+	if userID == "" {
+		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	challenges, err := h.ChallengeService.GetUserChallenges(userID)
+	if err != nil {
+		http.Error(w, "Error al obtener los retos", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(challenges)
+}
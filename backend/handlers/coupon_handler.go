@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/models"
+	"fidelity-client-app/services"
+	"net/http"
+)
+
+type CouponHandler struct {
+	CouponService *services.CouponService
+}
+
+// GenerateBatch maneja la solicitud para generar un lote de codigos de cupon
+func (h *CouponHandler) GenerateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		PromotionID string `json:"promotion_id"`
+		Name        string `json:"name"`
+		Prefix      string `json:"prefix"`
+		Length      int    `json:"length"`
+		Alphabet    string `json:"alphabet"`
+		Quantity    int    `json:"quantity"`
+		MaxUses     int    `json:"max_uses"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	batch := models.CouponBatch{
+		PromotionID: input.PromotionID,
+		Name:        input.Name,
+		Prefix:      input.Prefix,
+		Length:      input.Length,
+		Alphabet:    input.Alphabet,
+		Quantity:    input.Quantity,
+		MaxUses:     input.MaxUses,
+	}
+
+	codes, err := h.CouponService.GenerateBatch(&batch)
+	if err != nil {
+		writeCouponError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch": batch,
+		"codes": codes,
+	})
+}
+
+// ImportBatch maneja la solicitud para importar un lote de codigos de cupon
+func (h *CouponHandler) ImportBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		PromotionID string   `json:"promotion_id"`
+		Name        string   `json:"name"`
+		MaxUses     int      `json:"max_uses"`
+		Codes       []string `json:"codes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	batch := models.CouponBatch{
+		PromotionID: input.PromotionID,
+		Name:        input.Name,
+		MaxUses:     input.MaxUses,
+	}
+
+	codes, err := h.CouponService.ImportBatch(&batch, input.Codes)
+	if err != nil {
+		writeCouponError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch": batch,
+		"codes": codes,
+	})
+}
+
+// ExportBatch maneja la descarga en CSV de los codigos de un lote
+func (h *CouponHandler) ExportBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batchID := r.URL.Query().Get("batch_id")
+	if batchID == "" {
+		http.Error(w, "batch_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"coupons-"+batchID+".csv\"")
+	if err := h.CouponService.ExportBatchCSV(batchID, w); err != nil {
+		w.Header().Del("Content-Disposition")
+		if err.Error() == "lote no encontrado" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+}
+
+// RedeemCode maneja el canje de un codigo de cupon por parte de un usuario
+func (h *CouponHandler) RedeemCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// El codigo se canjea para el usuario autenticado
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "code es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := h.CouponService.RedeemCode(middleware.UserID(r), code)
+	if err != nil {
+		if err.Error() == "codigo no encontrado" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":      "Cupón canjeado exitosamente",
+		"promotion_id": usage.PromotionID,
+	})
+}
+
+// writeCouponError traduce los errores de los lotes de cupones a codigos HTTP
+func writeCouponError(w http.ResponseWriter, err error) {
+	if err.Error() == "promotion not found" {
+		http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/models"
+	"fidelity-client-app/services"
+	"net/http"
+)
+
+type ExperimentHandler struct {
+	ExperimentService *services.ExperimentService
+}
+
+// CreateExperiment maneja la solicitud para crear un experimento A/B entre promociones
+func (h *ExperimentHandler) CreateExperiment(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var experiment models.Experiment
+	if err := json.NewDecoder(r.Body).Decode(&experiment); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ExperimentService.CreateExperiment(&experiment, middleware.UserID(r)); err != nil {
+		switch err.Error() {
+		case "promotion not found":
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		case "alguna de las promociones ya forma parte de otro experimento en marcha":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(experiment)
+}
+
+// GetExperiments maneja la solicitud para listar los experimentos
+func (h *ExperimentHandler) GetExperiments(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodGet {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	experiments, err := h.ExperimentService.GetExperiments()
+	if err != nil {
+		http.Error(w, "Error al obtener los experimentos", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(experiments)
+}
+
+// StopExperiment maneja la solicitud para detener un experimento
+func (h *ExperimentHandler) StopExperiment(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "el id del experimento es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	experiment, err := h.ExperimentService.StopExperiment(id)
+	if err != nil {
+		switch err.Error() {
+		case "experimento no encontrado":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "el experimento ya esta detenido":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(experiment)
+}
+
+// GetExperimentReport maneja la solicitud del informe de conversion por variante
+func (h *ExperimentHandler) GetExperimentReport(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodGet {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "el id del experimento es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.ExperimentService.GetExperimentReport(id)
+	if err != nil {
+		if err.Error() == "experimento no encontrado" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
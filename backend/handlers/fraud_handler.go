@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/services"
+	"net/http"
+	"strconv"
+)
+
+type FraudHandler struct {
+	FraudService *services.FraudService
+}
+
+// GetAlerts maneja la solicitud para obtener la cola de revision de acumulaciones sospechosas
+func (h *FraudHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Por defecto se muestran solo las alertas abiertas
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = services.AlertOpen
+	}
+
+	alerts, err := h.FraudService.GetAlerts(status)
+	if err != nil {
+		http.Error(w, "Error al obtener las alertas", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// ReviewAlert maneja la aprobacion o rechazo de una acumulacion sospechosa
+func (h *FraudHandler) ReviewAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alertID := r.URL.Query().Get("alert_id")
+	if alertID == "" {
+		http.Error(w, "alert_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	approve, err := strconv.ParseBool(r.URL.Query().Get("approve"))
+	if err != nil {
+		http.Error(w, "approve debe ser true o false", http.StatusBadRequest)
+		return
+	}
+
+	// Queda registrado el usuario del token de acceso como revisor
+	if err := h.FraudService.ReviewAlert(alertID, approve, r.URL.Query().Get("note"), middleware.UserID(r)); err != nil {
+		switch err.Error() {
+		case "alerta no encontrada":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "no puede revisar sus propias acumulaciones":
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Alerta revisada correctamente",
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/services"
+	"net/http"
+	"strconv"
+)
+
+type LeaderboardHandler struct {
+	LeaderboardService *services.LeaderboardService
+}
+
+// GetLeaderboard maneja la solicitud para obtener el ranking de clientes de un mes
+func (h *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Si no se indica el mes se muestra el ranking del mes actual
+	month := r.URL.Query().Get("month")
+	storeID := r.URL.Query().Get("store_id")
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 10 // valor predeterminado
+	}
+
+	leaderboard, err := h.LeaderboardService.GetLeaderboard(month, storeID, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(leaderboard)
+}
+
+// SetLeaderboardOptOut maneja la solicitud de un usuario para salir o volver al ranking
+func (h *LeaderboardHandler) SetLeaderboardOptOut(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Cada cliente solo decide sobre su propia participacion en el ranking
+	userID := middleware.UserID(r)
+
+	optOut, err := strconv.ParseBool(r.URL.Query().Get("opt_out"))
+	if err != nil {
+		http.Error(w, "opt_out debe ser true o false", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.LeaderboardService.SetOptOut(userID, optOut); err != nil {
+		if err.Error() == "usuario no encontrado" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]bool{
+		"leaderboard_opt_out": optOut,
+	})
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"fidelity-client-app/middleware"
 	"fidelity-client-app/services"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type PointsHandler struct {
@@ -34,15 +36,31 @@ func (h *PointsHandler) AccumulatePoints(w http.ResponseWriter, r *http.Request)
 	}
 
 	purchaseAmount, err := strconv.ParseFloat(purchaseAmountStr, 64)
-	if err != nil {
+	if err != nil || !services.ValidPurchaseAmount(purchaseAmount) {
 		http.Error(w, "Monto de la compra no valido", http.StatusBadRequest)
 		return
 	}
 
-	// Llamar al servicio para acumular puntos y mensaje de respuesta
-	message, err := h.PointsService.AccumulatePoints(userID, purchaseAmount)
+	// Los productos comprados son opcionales y se usan para evaluar los retos
+	var products []string
+	if productsStr := r.URL.Query().Get("products"); productsStr != "" {
+		products = strings.Split(productsStr, ",")
+	}
+
+	// Llamar al servicio para acumular puntos y mensaje de respuesta. El empleado es el del token
+	// de acceso y la tienda la suya, para que las reglas antifraude no se puedan esquivar
+	message, err := h.PointsService.AccumulatePoints(services.PointsAccrual{
+		UserID:         userID,
+		StaffID:        middleware.UserID(r),
+		PurchaseAmount: purchaseAmount,
+		Products:       products,
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err.Error() == "el monto de la compra tiene que ser mayor que 0" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -52,3 +70,34 @@ func (h *PointsHandler) AccumulatePoints(w http.ResponseWriter, r *http.Request)
 		"message": message,
 	})
 }
+
+// GetTransactions maneja la solicitud del historial de movimientos de puntos de un usuario paginado por cursor
+func (h *PointsHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := targetUserID(r)
+	if userID == "" {
+		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	// Sin cursor se devuelve la primera pagina
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	transactions, next, err := h.PointsService.GetTransactions(userID, r.URL.Query().Get("cursor"), pageSize)
+	if err != nil {
+		if err.Error() == "el cursor no es valido" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Error al obtener los movimientos de puntos", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transactions": transactions,
+		"next_cursor":  next,
+	})
+}
@@ -2,10 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fidelity-client-app/config"
+	"fidelity-client-app/middleware"
 	"fidelity-client-app/models"
 	"fidelity-client-app/services"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,12 +32,16 @@ func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Reques
 
 	// Decodificamoso el JSON recibido en el cuerpo de la solicitud
 	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		if errors.Is(err, models.ErrInvalidDate) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
 
-	// Llamamos al servicio para crear la promocion
-	if err := h.PromotionService.CreatePromotion(&promotion); err != nil {
+	// Llamamos al servicio para crear la promocion, el autor de la revision se obtiene del token de acceso
+	if err := h.PromotionService.CreatePromotion(&promotion, middleware.UserID(r)); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -50,19 +60,30 @@ func (h *PromotionHandler) GetActivePromotions(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Procesamos los parametros "page" y "pageSize" de la URL
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
-	if err != nil || page < 1 {
-		page = 1 // valor predeterminado
-	}
+	// Usamos la fecha actual para filtrar las promociones activas
+	currentDate := time.Now()
 
-	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
-	if err != nil || pageSize < 1 {
-		pageSize = 10 // valor predeterminado
+	// Con el parametro "cursor" (vacio para la primera pagina) se pagina por cursor en lugar de por offset
+	if r.URL.Query().Has("cursor") {
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		promotions, next, err := h.PromotionService.GetActivePromotionsPage(currentDate, r.URL.Query().Get("cursor"), pageSize)
+		if err != nil {
+			if err.Error() == "el cursor no es valido" {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"promotions":  promotions,
+			"next_cursor": next,
+		})
+		return
 	}
 
-	// Usamos la fecha actual para filtrar las promociones activas
-	currentDate := time.Now()
+	// Procesamos los parametros "page" y "pageSize" de la URL
+	page, pageSize := parsePagination(r)
 
 	// Obtenemos las promociones activas con el servicio
 	promotions, total, err := h.PromotionService.GetActivePromotions(currentDate, page, pageSize)
@@ -71,21 +92,114 @@ func (h *PromotionHandler) GetActivePromotions(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Estructuramos la respuesta incluyendo datos de paginacion
-	response := map[string]interface{}{
+	// Devolvemos la respuesta como json incluyendo datos de paginacion
+	json.NewEncoder(w).Encode(paginatedPromotions(promotions, total, page, pageSize))
+}
+
+// SearchPromotions maneja el listado de promociones para administracion con busqueda, filtros y orden
+func (h *PromotionHandler) SearchPromotions(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodGet {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	page, pageSize := parsePagination(r)
+
+	// Procesamos los filtros de la URL
+	filter := services.PromotionFilter{
+		Query:   query.Get("q"),
+		StoreID: query.Get("store_id"),
+		Deleted: query.Get("deleted"),
+		Sort:    query.Get("sort"),
+		Desc:    query.Get("order") == "desc",
+	}
+	if value := query.Get("level"); value != "" {
+		level, err := strconv.Atoi(value)
+		if err != nil || level < 1 {
+			http.Error(w, "el nivel debe ser un numero mayor que cero", http.StatusBadRequest)
+			return
+		}
+		filter.Level = level
+	}
+	if value := query.Get("from"); value != "" {
+		from, err := models.ParseDate(value)
+		if err != nil {
+			http.Error(w, "el formato de from debe ser YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filter.From = &from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := models.ParseDate(value)
+		if err != nil {
+			http.Error(w, "el formato de to debe ser YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filter.To = &to
+	}
+	if value := query.Get("status"); value != "" {
+		filter.Statuses = strings.Split(value, ",")
+	}
+
+	promotions, total, err := h.PromotionService.SearchPromotions(filter, page, pageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(paginatedPromotions(promotions, total, page, pageSize))
+}
+
+// parsePagination obtiene los parametros "page" y "pageSize" de la URL con sus valores predeterminados
+func parsePagination(r *http.Request) (int, int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1 // valor predeterminado
+	}
+
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10 // valor predeterminado
+	}
+	return page, pageSize
+}
+
+// paginatedPromotions estructura la respuesta de un listado de promociones con los datos de paginacion
+func paginatedPromotions(promotions []models.Promotion, total int64, page, pageSize int) map[string]interface{} {
+	return map[string]interface{}{
 		"promotions": promotions,
 		"total":      total,
 		"page":       page,
 		"pageSize":   pageSize,
 		"totalPages": (total + int64(pageSize) - 1) / int64(pageSize),
 	}
+}
 
-	// Devolvemos la respuesta como json
-	json.NewEncoder(w).Encode(response)
+// targetUserID devuelve el usuario cuyos datos se consultan: los clientes solo pueden ver los suyos
+// y el personal puede indicar el cliente con el parametro user_id
+func targetUserID(r *http.Request) string {
+	if middleware.Role(r) == models.RoleCustomer {
+		return middleware.UserID(r)
+	}
+	return r.URL.Query().Get("user_id")
 }
 
-// GetPromotionByID maneja la solicitud para obtener una promocion especifica
+// GetPromotionByID maneja la solicitud publica para obtener una promocion visible para los clientes
 func (h *PromotionHandler) GetPromotionByID(w http.ResponseWriter, r *http.Request) {
+	h.writePromotionByID(w, r, h.PromotionService.GetVisiblePromotionByID)
+}
+
+// GetPromotionDetail maneja la solicitud del equipo de marketing para obtener una promocion en
+// cualquier estado, con su version como ETag para las modificaciones
+func (h *PromotionHandler) GetPromotionDetail(w http.ResponseWriter, r *http.Request) {
+	h.writePromotionByID(w, r, h.PromotionService.GetPromotionByID)
+}
+
+// writePromotionByID busca la promocion del parametro id con la funcion indicada y la envia
+func (h *PromotionHandler) writePromotionByID(w http.ResponseWriter, r *http.Request, find func(string) (*models.Promotion, error)) {
 
 	// Establecemos metodo permitido
 	if r.Method != http.MethodGet {
@@ -100,8 +214,7 @@ func (h *PromotionHandler) GetPromotionByID(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Llamamos al servicio de GetPromotionByID
-	promotion, err := h.PromotionService.GetPromotionByID(id)
+	promotion, err := find(id)
 	if err != nil {
 		if err.Error() == "promotion not found" {
 			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
@@ -111,7 +224,8 @@ func (h *PromotionHandler) GetPromotionByID(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Enviamos los detalles de la promocion al servidor
+	// Enviamos los detalles de la promocion con su version como ETag para las modificaciones
+	w.Header().Set("ETag", promotionETag(promotion.Version))
 	json.NewEncoder(w).Encode(promotion)
 }
 
@@ -134,24 +248,113 @@ func (h *PromotionHandler) UpdatePromotion(w http.ResponseWriter, r *http.Reques
 	// Decodificamos el JSON recibido en el cuerpo de la solicitud
 	var updatedPromotion models.Promotion
 	if err := json.NewDecoder(r.Body).Decode(&updatedPromotion); err != nil {
+		if errors.Is(err, models.ErrInvalidDate) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
 
-	// Llamamos al servicio para actualizar la promocion
-	if err := h.PromotionService.UpdatePromotion(id, &updatedPromotion); err != nil {
-		if err.Error() == "promotion not found" {
-			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
+	// Para no sobrescribir cambios de otros usuarios la actualizacion exige la version que se ha leido
+	if r.Header.Get("If-Match") == "" {
+		http.Error(w, "la cabecera If-Match es obligatoria", http.StatusPreconditionRequired)
+		return
+	}
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Enviamos una respuesta con el codigo 200
+	// Llamamos al servicio para actualizar la promocion, el autor de la revision se obtiene del token de acceso
+	if err := h.PromotionService.UpdatePromotion(id, &updatedPromotion, expectedVersion, middleware.UserID(r)); err != nil {
+		writePromotionEditError(w, err)
+		return
+	}
+
+	// Enviamos una respuesta con el codigo 200 y la nueva version
+	w.Header().Set("ETag", promotionETag(updatedPromotion.Version))
 	w.WriteHeader(http.StatusOK)
 }
 
+// PatchPromotion maneja la solicitud para modificar parcialmente una promocion con un JSON merge patch
+func (h *PromotionHandler) PatchPromotion(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPatch {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "el id de la promocion es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/merge-patch+json") && !strings.HasPrefix(contentType, "application/json") {
+		http.Error(w, "el Content-Type debe ser application/merge-patch+json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Para no sobrescribir cambios de otros usuarios el parche exige la version que se ha leido
+	if r.Header.Get("If-Match") == "" {
+		http.Error(w, "la cabecera If-Match es obligatoria", http.StatusPreconditionRequired)
+		return
+	}
+	version, err := parseIfMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	promotion, err := h.PromotionService.PatchPromotion(id, patch, version, middleware.UserID(r))
+	if err != nil {
+		writePromotionEditError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", promotionETag(promotion.Version))
+	json.NewEncoder(w).Encode(promotion)
+}
+
+// promotionETag genera la ETag de una promocion a partir de su version
+func promotionETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// parseIfMatch obtiene la version de la promocion de la cabecera If-Match
+func parseIfMatch(r *http.Request) (int, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-Match")), "W/")
+	version, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil {
+		return 0, errors.New("la cabecera If-Match no es valida")
+	}
+	return version, nil
+}
+
+// writePromotionEditError responde con el codigo adecuado al error de una modificacion de promocion
+func writePromotionEditError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case "promotion not found":
+		http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+	case "la promocion ha sido modificada por otro usuario":
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case "no se puede modificar una promocion archivada":
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
 // DeletePromotion maneja la solicitud para eliminar una promocion especifica
 func (h *PromotionHandler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
 
@@ -182,22 +385,218 @@ func (h *PromotionHandler) DeletePromotion(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// GetActivePromotionsForUser maneja la obtención de promociones activas no consumidas por el usuario
+// GetPromotionHistory maneja la solicitud para obtener las revisiones de una promocion y sus cambios
+func (h *PromotionHandler) GetPromotionHistory(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodGet {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "el id de la promocion es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.PromotionService.GetPromotionHistory(id)
+	if err != nil {
+		if err.Error() == "promotion not found" {
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
+
+// RestorePromotion maneja la solicitud para recuperar una promocion eliminada
+func (h *PromotionHandler) RestorePromotion(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "El ID de la promoción es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.PromotionService.RestorePromotion(id); err != nil {
+		if err.Error() == "promotion not found" {
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		} else {
+			http.Error(w, "Error interno en el servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Promoción restaurada"})
+}
+
+// PurgePromotions maneja la solicitud para purgar las promociones eliminadas fuera del periodo de retencion
+func (h *PromotionHandler) PurgePromotions(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	purged, kept, err := h.PromotionService.PurgeDeletedPromotions(time.Now(), config.Vars.PromotionRetentionDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{
+		"purged": purged,
+		"kept":   kept, // Promociones con usos o referencias que se conservan para los informes
+	})
+}
+
+// maxPromotionImportBytes limita el tamaño de los ficheros de importacion de promociones
+const maxPromotionImportBytes = 10 << 20
+
+// ImportPromotions maneja la importacion en bloque de promociones desde un fichero CSV o JSON
+func (h *PromotionHandler) ImportPromotions(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPromotionImportBytes))
+	if err != nil {
+		http.Error(w, "el fichero supera el tamaño maximo permitido", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	result, err := h.PromotionService.ImportPromotions(data, format, dryRun, middleware.UserID(r))
+	if err != nil {
+		if err.Error() == "error al importar las promociones" {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	// Si alguna fila no es valida no se ha creado ninguna promocion
+	switch {
+	case result.DryRun:
+		w.WriteHeader(http.StatusOK)
+	case len(result.Errors) > 0:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportPromotions maneja la exportacion de todas las promociones en CSV o JSON
+func (h *PromotionHandler) ExportPromotions(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodGet {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = services.FormatJSON
+	}
+	if format != services.FormatCSV && format != services.FormatJSON {
+		http.Error(w, "el formato debe ser csv o json", http.StatusBadRequest)
+		return
+	}
+
+	if format == services.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\"promotions."+format+"\"")
+	if err := h.PromotionService.ExportPromotions(format, w); err != nil {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// TransitionPromotion maneja la solicitud para cambiar el estado del ciclo de vida de una promocion
+func (h *PromotionHandler) TransitionPromotion(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	action := r.URL.Query().Get("action")
+	if id == "" || action == "" {
+		http.Error(w, "id y action son obligatorios", http.StatusBadRequest)
+		return
+	}
+
+	// El rol y el usuario se obtienen del token de acceso
+	promotion, err := h.PromotionService.TransitionPromotion(id, action, middleware.UserID(r), middleware.Role(r))
+	if err != nil {
+		switch {
+		case err.Error() == "promotion not found":
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		case err.Error() == "no tiene permisos para realizar esta accion":
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case strings.HasPrefix(err.Error(), "la promocion en estado"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(promotion)
+}
+
+// GetActivePromotionsForUser maneja la obtención de promociones activas con su elegibilidad para el usuario
 func (h *PromotionHandler) GetActivePromotionsForUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
 		return
 	}
 
-	userID := r.URL.Query().Get("user_id")
+	userID := targetUserID(r)
 	if userID == "" {
 		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
 		return
 	}
 
-	promotions, err := h.PromotionService.GetActivePromotionsForUser(userID)
+	// Con eligible_only=true solo se devuelven las promociones que el usuario puede consumir
+	eligibleOnly := r.URL.Query().Get("eligible_only") == "true"
+
+	// Solo cuenta como exposicion a los experimentos cuando el listado lo consulta el propio cliente
+	recordExposure := middleware.Role(r) == models.RoleCustomer
+
+	promotions, err := h.PromotionService.GetActivePromotionsForUser(userID, eligibleOnly, recordExposure)
 	if err != nil {
-		http.Error(w, "Error al obtener promociones", http.StatusInternalServerError)
+		if err.Error() == "usuario no encontrado" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Error al obtener promociones", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -213,24 +612,161 @@ func (h *PromotionHandler) ConsumePromotion(w http.ResponseWriter, r *http.Reque
 	}
 
 	userID := r.URL.Query().Get("user_id")
-	promotionID := r.URL.Query().Get("promotion_id")
-	if userID == "" || promotionID == "" {
+	if userID == "" {
+		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	// Se pueden consumir varias promociones en la misma compra separando los ids por comas
+	var promotionIDs []string
+	for _, id := range strings.Split(r.URL.Query().Get("promotion_id"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			promotionIDs = append(promotionIDs, id)
+		}
+	}
+
+	// La cesta es opcional: con ella se devuelve el descuento de la combinacion y, si no se indican
+	// promociones, se consume la mejor combinacion posible
+	var basket *services.Basket
+	var input services.Basket
+	switch err := json.NewDecoder(r.Body).Decode(&input); {
+	case err == nil:
+		basket = &input
+	case err != io.EOF:
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if len(promotionIDs) == 0 && basket == nil {
 		http.Error(w, "user_id y promotion_id son obligatorios", http.StatusBadRequest)
 		return
 	}
 
-	err := h.PromotionService.ConsumePromotion(userID, promotionID)
+	combination, err := h.PromotionService.ConsumePromotions(userID, promotionIDs, basket)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if strings.Contains(err.Error(), "no se puede combinar") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
+	response := map[string]interface{}{
 		"message": "Promoción consumida exitosamente",
+	}
+	if combination != nil {
+		response["combination"] = combination
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetBestCombination maneja la solicitud de la mejor combinacion de promociones para una cesta
+func (h *PromotionHandler) GetBestCombination(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	var basket services.Basket
+	if err := json.NewDecoder(r.Body).Decode(&basket); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	combination, err := h.PromotionService.GetBestCombination(userID, basket)
+	if err != nil {
+		if err.Error() == "usuario no encontrado" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(combination)
+}
+
+// GetUsageHistory maneja la solicitud del historial de consumos de promociones de un usuario paginado por cursor
+func (h *PromotionHandler) GetUsageHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := targetUserID(r)
+	if userID == "" {
+		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	// Sin cursor se devuelve la primera pagina
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	usages, next, err := h.PromotionService.GetUsageHistory(userID, r.URL.Query().Get("cursor"), pageSize)
+	if err != nil {
+		if err.Error() == "el cursor no es valido" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Error al obtener el historial de consumos", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"usages":      usages,
+		"next_cursor": next,
 	})
 }
 
+// VoidUsage maneja la solicitud del personal para anular un consumo registrado por error
+func (h *PromotionHandler) VoidUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	usageID := r.URL.Query().Get("id")
+	if usageID == "" {
+		http.Error(w, "id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// El plazo para anular se configura con USAGE_VOID_WINDOW_MINUTES
+	window := time.Duration(config.Vars.UsageVoidWindowMinutes) * time.Minute
+	usage, err := h.PromotionService.VoidUsage(usageID, input.Reason, middleware.UserID(r), window, time.Now())
+	if err != nil {
+		switch err.Error() {
+		case "consumo no encontrado":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "el consumo ya ha sido anulado", "el plazo para anular el consumo ha terminado":
+			http.Error(w, err.Error(), http.StatusConflict)
+		case "el motivo de la anulacion es obligatorio", "el motivo de la anulacion no puede superar los 250 caracteres":
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usage)
+}
+
 // CheckPromotionAvailability maneja la solicitud para verificar si una promoción ya fue consumida
 func (h *PromotionHandler) CheckPromotionAvailability(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -256,3 +792,37 @@ func (h *PromotionHandler) CheckPromotionAvailability(w http.ResponseWriter, r *
 		"is_consumed": isConsumed,
 	})
 }
+
+// CalculateDiscount maneja la solicitud del personal para calcular el descuento de una promocion sobre una cesta
+func (h *PromotionHandler) CalculateDiscount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	promotionID := r.URL.Query().Get("promotion_id")
+	if promotionID == "" {
+		http.Error(w, "promotion_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	// Decodificamos la cesta recibida en el cuerpo de la solicitud
+	var basket services.Basket
+	if err := json.NewDecoder(r.Body).Decode(&basket); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	discount, err := h.PromotionService.CalculateBasketDiscount(promotionID, basket)
+	if err != nil {
+		if err.Error() == "promotion not found" {
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(discount)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/config"
+	"fidelity-client-app/services"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type PromotionImageHandler struct {
+	PromotionImageService *services.PromotionImageService
+}
+
+// UploadImage maneja la subida de la imagen de una promocion en el campo `image` de un formulario multipart
+func (h *PromotionImageHandler) UploadImage(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "el id de la promocion es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	// Limitamos el tamaño de la solicitud antes de leer el formulario
+	maxBytes := int64(config.Vars.MaxImageBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "la imagen es obligatoria y no puede superar el tamaño maximo", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		http.Error(w, "la imagen supera el tamaño maximo", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	images, err := h.PromotionImageService.UploadImage(id, data)
+	if err != nil {
+		switch err.Error() {
+		case "promotion not found":
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		case "la imagen debe ser JPEG o PNG":
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		case "error al procesar la imagen", "error al guardar la imagen":
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(images)
+}
+
+// DeleteImages maneja la solicitud para quitar la imagen de una promocion
+func (h *PromotionImageHandler) DeleteImages(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodDelete {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "el id de la promocion es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.PromotionImageService.DeleteImages(id); err != nil {
+		if err.Error() == "la promocion no tiene imagenes" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MediaFileServer sirve los ficheros del almacenamiento local sin listar el contenido de los directorios
+func MediaFileServer(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/models"
+	"fidelity-client-app/services"
+	"net/http"
+	"strconv"
+)
+
+type ReceiptHandler struct {
+	ReceiptService *services.ReceiptService
+}
+
+// SubmitPosTransaction maneja el envio de un ticket desde el TPV de una tienda
+func (h *ReceiptHandler) SubmitPosTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var transaction models.PosTransaction
+	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ReceiptService.SubmitPosTransaction(&transaction); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// ClaimReceipt maneja la reclamacion de puntos de un ticket por parte de un cliente
+func (h *ReceiptHandler) ClaimReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Decodificamos los datos del ticket
+	var input struct {
+		StoreID       string  `json:"store_id"`
+		ReceiptNumber string  `json:"receipt_number"`
+		Date          string  `json:"date"`
+		Total         float64 `json:"total"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// Los puntos se reclaman para el usuario autenticado
+	claim := models.ReceiptClaim{
+		UserID:        middleware.UserID(r),
+		StoreID:       input.StoreID,
+		ReceiptNumber: input.ReceiptNumber,
+		Date:          input.Date,
+		Total:         input.Total,
+	}
+
+	message, err := h.ReceiptService.ClaimReceipt(&claim)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Respondemos 202 si la reclamacion queda pendiente de revision
+	if claim.Status == services.ClaimPending {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"claim":   claim,
+	})
+}
+
+// GetPendingClaims maneja la solicitud del personal para ver las reclamaciones pendientes
+func (h *ReceiptHandler) GetPendingClaims(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, err := h.ReceiptService.GetPendingClaims()
+	if err != nil {
+		http.Error(w, "Error al obtener las reclamaciones", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(claims)
+}
+
+// ReviewClaim maneja la aprobacion o rechazo de una reclamacion por parte del personal
+func (h *ReceiptHandler) ReviewClaim(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claimID := r.URL.Query().Get("claim_id")
+	if claimID == "" {
+		http.Error(w, "claim_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	approve, err := strconv.ParseBool(r.URL.Query().Get("approve"))
+	if err != nil {
+		http.Error(w, "approve debe ser true o false", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.ReceiptService.ReviewClaim(claimID, approve, r.URL.Query().Get("reason"), middleware.UserID(r))
+	if err != nil {
+		switch err.Error() {
+		case "reclamacion no encontrada":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "no puede revisar sus propias reclamaciones":
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": message,
+	})
+}
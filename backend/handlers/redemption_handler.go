@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/services"
+	"net/http"
+	"strconv"
+)
+
+type RedemptionHandler struct {
+	RedemptionService *services.RedemptionService
+}
+
+// IssueToken maneja la solicitud de la app para obtener un token de canje de una promocion
+func (h *RedemptionHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// El token se emite para el usuario autenticado, no para uno indicado en la solicitud
+	promotionID := r.URL.Query().Get("promotion_id")
+	if promotionID == "" {
+		http.Error(w, "promotion_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	token, expiresAt, err := h.RedemptionService.IssueToken(middleware.UserID(r), promotionID)
+	if err != nil {
+		if err.Error() == "promotion not found" {
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
+
+// GetQRCode maneja la solicitud para obtener el codigo QR de un token de canje
+func (h *RedemptionHandler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = services.QRFormatPNG // valor predeterminado
+	}
+
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size < 64 || size > 1024 {
+		size = 256 // valor predeterminado
+	}
+
+	image, contentType, err := h.RedemptionService.RenderQR(token, format, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// El QR no debe cachearse porque el token caduca en poco tiempo
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// RedeemToken maneja el escaneo de un token de canje por parte del personal
+func (h *RedemptionHandler) RedeemToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Token == "" {
+		http.Error(w, "token es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := h.RedemptionService.RedeemToken(input.Token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":      "Promoción consumida exitosamente",
+		"user_id":      usage.UserID,
+		"promotion_id": usage.PromotionID,
+	})
+}
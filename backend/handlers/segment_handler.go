@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/models"
+	"fidelity-client-app/services"
+	"net/http"
+)
+
+type SegmentHandler struct {
+	SegmentService *services.SegmentService
+}
+
+// CreateSegment maneja la solicitud para crear un nuevo segmento de clientes
+func (h *SegmentHandler) CreateSegment(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var segment models.Segment
+
+	// Decodificamos el JSON recibido en el cuerpo de la solicitud
+	if err := json.NewDecoder(r.Body).Decode(&segment); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// Llamamos al servicio para crear el segmento
+	if err := h.SegmentService.CreateSegment(&segment); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Enviamos una respuesta 201 para indicar creacion exitosa
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(segment)
+}
+
+// GetSegments maneja la solicitud para obtener los segmentos guardados
+func (h *SegmentHandler) GetSegments(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodGet {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	segments, err := h.SegmentService.GetSegments()
+	if err != nil {
+		http.Error(w, "Error al obtener los segmentos", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(segments)
+}
+
+// DeleteSegment maneja la solicitud para eliminar un segmento
+func (h *SegmentHandler) DeleteSegment(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodDelete {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "el id del segmento es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.SegmentService.DeleteSegment(id); err != nil {
+		switch err.Error() {
+		case "segmento no encontrado":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "el segmento esta asignado a promociones":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Enviamos una respuesta con codigo 204 para indicar que el segmento fue eliminado
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/models"
+	"fidelity-client-app/services"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type StampCardHandler struct {
+	StampCardService *services.StampCardService
+}
+
+// CreateStampCard maneja la solicitud para crear un nuevo programa de sellos
+func (h *StampCardHandler) CreateStampCard(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodPost {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var card models.StampCard
+
+	// Decodificamos el JSON recibido en el cuerpo de la solicitud
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// Llamamos al servicio para crear el programa de sellos
+	if err := h.StampCardService.CreateStampCard(&card); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Enviamos una respuesta 201 para indicar creacion exitosa
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(card)
+}
+
+// GetActiveStampCards maneja la solicitud para obtener los programas de sellos activos
+func (h *StampCardHandler) GetActiveStampCards(w http.ResponseWriter, r *http.Request) {
+
+	// Establecemos metodo permitido
+	if r.Method != http.MethodGet {
+		http.Error(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cards, err := h.StampCardService.GetActiveStampCards(time.Now())
+	if err != nil {
+		http.Error(w, "Error al obtener las tarjetas de sellos", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(cards)
+}
+
+// GetUserStampCards maneja la solicitud para obtener las tarjetas y recompensas de un usuario
+func (h *StampCardHandler) GetUserStampCards(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := targetUserID(r)
+	if userID == "" {
+		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	cards, rewards, err := h.StampCardService.GetUserStampCards(userID)
+	if err != nil {
+		http.Error(w, "Error al obtener las tarjetas de sellos", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cards":   cards,
+		"rewards": rewards,
+	})
+}
+
+// AddStamps maneja la solicitud del personal para sellar las tarjetas de un cliente
+func (h *StampCardHandler) AddStamps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Obtener el usuario y el producto o categoria comprados de los parametros de consulta
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "user_id es obligatorio", http.StatusBadRequest)
+		return
+	}
+	product := r.URL.Query().Get("product")
+	category := r.URL.Query().Get("category")
+
+	// La cantidad es opcional y por defecto se añade un sello
+	quantity := 1
+	if quantityStr := r.URL.Query().Get("quantity"); quantityStr != "" {
+		var err error
+		quantity, err = strconv.Atoi(quantityStr)
+		if err != nil {
+			http.Error(w, "Cantidad no valida", http.StatusBadRequest)
+			return
+		}
+	}
+
+	cards, rewards, err := h.StampCardService.AddStamps(userID, product, category, quantity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cards":          cards,
+		"rewards_issued": rewards,
+	})
+}
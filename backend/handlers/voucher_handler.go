@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/models"
+	"fidelity-client-app/services"
+	"net/http"
+	"time"
+)
+
+type VoucherHandler struct {
+	VoucherService *services.VoucherService
+}
+
+// IssueVoucher maneja la solicitud para emitir un vale personal de una promocion
+func (h *VoucherHandler) IssueVoucher(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		PromotionID string    `json:"promotion_id"`
+		UserID      string    `json:"user_id"`
+		Reason      string    `json:"reason"`
+		ExpiresAt   time.Time `json:"expires_at"` // Formato RFC 3339
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if input.PromotionID == "" || input.UserID == "" {
+		http.Error(w, "promotion_id y user_id son obligatorios", http.StatusBadRequest)
+		return
+	}
+
+	voucher := models.Voucher{
+		PromotionID: input.PromotionID,
+		UserID:      input.UserID,
+		Reason:      input.Reason,
+		ExpiresAt:   input.ExpiresAt,
+		IssuedBy:    middleware.UserID(r),
+	}
+	if err := h.VoucherService.IssueVoucher(&voucher, time.Now()); err != nil {
+		switch err.Error() {
+		case "promotion not found":
+			http.Error(w, "Promoción no encontrada", http.StatusNotFound)
+		case "usuario no encontrado":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(voucher)
+}
+
+// GetWallet maneja la solicitud de los vales personales del usuario
+func (h *VoucherHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Solo se muestran los vales del usuario autenticado
+	vouchers, err := h.VoucherService.GetWallet(middleware.UserID(r), r.URL.Query().Get("status"), time.Now())
+	if err != nil {
+		if err.Error() == "el estado no es valido" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(vouchers)
+}
+
+// ViewVoucher maneja la solicitud para marcar un vale como visto
+func (h *VoucherHandler) ViewVoucher(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// El vale tiene que ser del usuario autenticado
+	userID := middleware.UserID(r)
+	voucherID := r.URL.Query().Get("id")
+	if voucherID == "" {
+		http.Error(w, "id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	voucher, err := h.VoucherService.ViewVoucher(userID, voucherID, time.Now())
+	if err != nil {
+		if err.Error() == "vale no encontrado" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(voucher)
+}
+
+// RedeemVoucher maneja la solicitud para canjear un vale personal
+func (h *VoucherHandler) RedeemVoucher(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// El vale tiene que ser del usuario autenticado
+	userID := middleware.UserID(r)
+	voucherID := r.URL.Query().Get("id")
+	if voucherID == "" {
+		http.Error(w, "id es obligatorio", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := h.VoucherService.RedeemVoucher(userID, voucherID, time.Now())
+	if err != nil {
+		switch err.Error() {
+		case "vale no encontrado":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "el vale ya ha sido canjeado", "el vale ha caducado":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":      "Vale canjeado exitosamente",
+		"promotion_id": usage.PromotionID,
+	})
+}
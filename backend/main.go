@@ -4,8 +4,12 @@ import (
 	"fidelity-client-app/config"
 	"fidelity-client-app/database"
 	"fidelity-client-app/handlers"
+	"fidelity-client-app/middleware"
+	"fidelity-client-app/models"
 	"fidelity-client-app/services"
+	"log"
 	"net/http"
+	"time"
 )
 
 func main() {
@@ -18,33 +22,148 @@ func main() {
 
 	// Inicializar servicios
 	authService := services.AuthService{DB: DB}
-	promotionService := services.PromotionService{DB: DB}
-	pointsService := services.PointsService{DB: DB} // Servicio de puntos
+	imageStorage := &services.LocalImageStorage{Dir: config.Vars.MediaDir, BaseURL: config.Vars.MediaBaseURL}
+	promotionService := services.PromotionService{DB: DB, Storage: imageStorage}
+	challengeService := services.ChallengeService{DB: DB}
+	fraudService := services.FraudService{DB: DB, Rules: services.DefaultFraudRules()}
+	couponService := services.CouponService{DB: DB, PromotionService: &promotionService}
+	redemptionService := services.RedemptionService{DB: DB, PromotionService: &promotionService}
+	pointsService := services.PointsService{DB: DB, ChallengeService: &challengeService, FraudService: &fraudService} // Servicio de puntos
+	stampCardService := services.StampCardService{DB: DB}
+	leaderboardService := services.LeaderboardService{DB: DB}
+	receiptService := services.ReceiptService{DB: DB, PointsService: &pointsService}
+	segmentService := services.SegmentService{DB: DB}
+	experimentService := services.ExperimentService{DB: DB}
+	voucherService := services.VoucherService{DB: DB, PromotionService: &promotionService}
+	promotionImageService := services.PromotionImageService{DB: DB, Storage: imageStorage}
 
 	// Inicializar handlers
 	authHandler := handlers.AuthHandler{AuthService: authService}
 	promotionHandler := handlers.PromotionHandler{PromotionService: &promotionService}
 	pointsHandler := handlers.PointsHandler{PointsService: &pointsService} // Handler de puntos
+	stampCardHandler := handlers.StampCardHandler{StampCardService: &stampCardService}
+	challengeHandler := handlers.ChallengeHandler{ChallengeService: &challengeService}
+	leaderboardHandler := handlers.LeaderboardHandler{LeaderboardService: &leaderboardService}
+	receiptHandler := handlers.ReceiptHandler{ReceiptService: &receiptService}
+	fraudHandler := handlers.FraudHandler{FraudService: &fraudService}
+	couponHandler := handlers.CouponHandler{CouponService: &couponService}
+	redemptionHandler := handlers.RedemptionHandler{RedemptionService: &redemptionService}
+	segmentHandler := handlers.SegmentHandler{SegmentService: &segmentService}
+	experimentHandler := handlers.ExperimentHandler{ExperimentService: &experimentService}
+	voucherHandler := handlers.VoucherHandler{VoucherService: &voucherService}
+	promotionImageHandler := handlers.PromotionImageHandler{PromotionImageService: &promotionImageService}
 
 	// Iniciar enrutador
 	mux := http.NewServeMux()
 
+	// Roles que pueden acceder a las rutas protegidas
+	marketingRoles := []string{models.RoleMarketing, models.RoleMarketingManager, models.RoleAdmin}
+	managerRoles := []string{models.RoleMarketingManager, models.RoleAdmin}
+	staffRoles := []string{models.RoleStaff, models.RoleAdmin}
+	customerAndStaffRoles := []string{models.RoleCustomer, models.RoleStaff, models.RoleAdmin}
+
 	// Rutas publicas de Auth
 	mux.HandleFunc("/api/v1/register", authHandler.RegisterNewUser)
 	mux.HandleFunc("/api/v1/login", authHandler.LoginUser)
 
 	// Rutas para promociones
-	mux.HandleFunc("/api/v1/promotions/create", promotionHandler.CreatePromotion)                     // POST: Crear promoción
-	mux.HandleFunc("/api/v1/promotions/active", promotionHandler.GetActivePromotions)                 // GET: Obtener promociones activas (con paginación)
-	mux.HandleFunc("/api/v1/promotions", promotionHandler.GetPromotionByID)                           // GET: Obtener promoción por ID
-	mux.HandleFunc("/api/v1/promotions/update", promotionHandler.UpdatePromotion)                     // PUT: Actualizar promoción
-	mux.HandleFunc("/api/v1/promotions/delete", promotionHandler.DeletePromotion)                     // DELETE: Eliminar promoción
-	mux.HandleFunc("/api/v1/promotions/active_for_user", promotionHandler.GetActivePromotionsForUser) // Promociones activas no consumidas por usuario
-	mux.HandleFunc("/api/v1/promotions/consume", promotionHandler.ConsumePromotion)                   // Consumir promoción
-	mux.HandleFunc("/api/v1/promotions/check", promotionHandler.CheckPromotionAvailability)           // Verificar si la promoción ha sido consumida
+	mux.HandleFunc("/api/v1/promotions/active", promotionHandler.GetActivePromotions)                                                                    // GET: Obtener promociones activas (paginación por page o por cursor)
+	mux.HandleFunc("/api/v1/promotions", promotionHandler.GetPromotionByID)                                                                              // GET: Obtener promoción visible para clientes por ID
+	mux.HandleFunc("/api/v1/promotions/active_for_user", middleware.RequireRoles(promotionHandler.GetActivePromotionsForUser, customerAndStaffRoles...)) // Promociones activas no consumidas por usuario
+	mux.HandleFunc("/api/v1/promotions/check", promotionHandler.CheckPromotionAvailability)                                                              // Verificar si la promoción ha sido consumida
+	mux.HandleFunc("/api/v1/promotions/calculate", middleware.RequireRoles(promotionHandler.CalculateDiscount, staffRoles...))                           // POST: Calcular descuento sobre una cesta
+
+	// Rutas de gestion de promociones que requieren un rol de marketing o administracion
+	mux.HandleFunc("/api/v1/promotions/create", middleware.RequireRoles(promotionHandler.CreatePromotion, marketingRoles...))          // POST: Crear promoción en borrador
+	mux.HandleFunc("/api/v1/promotions/detail", middleware.RequireRoles(promotionHandler.GetPromotionDetail, marketingRoles...))       // GET: Obtener promoción en cualquier estado con ETag
+	mux.HandleFunc("/api/v1/promotions/update", middleware.RequireRoles(promotionHandler.UpdatePromotion, marketingRoles...))          // PUT: Actualizar promoción
+	mux.HandleFunc("/api/v1/promotions/patch", middleware.RequireRoles(promotionHandler.PatchPromotion, marketingRoles...))            // PATCH: Modificar campos con JSON merge patch e If-Match
+	mux.HandleFunc("/api/v1/promotions/history", middleware.RequireRoles(promotionHandler.GetPromotionHistory, marketingRoles...))     // GET: Revisiones de la promoción y sus cambios
+	mux.HandleFunc("/api/v1/promotions/search", middleware.RequireRoles(promotionHandler.SearchPromotions, marketingRoles...))         // GET: Listado completo con busqueda (q), filtros (level, from, to, status, store_id, deleted) y orden (sort, order)
+	mux.HandleFunc("/api/v1/promotions/transition", middleware.RequireRoles(promotionHandler.TransitionPromotion, marketingRoles...))  // POST: Cambiar estado del ciclo de vida (submit, approve...)
+	mux.HandleFunc("/api/v1/promotions/delete", middleware.RequireRoles(promotionHandler.DeletePromotion, managerRoles...))            // DELETE: Eliminar promoción (borrado logico)
+	mux.HandleFunc("/api/v1/promotions/restore", middleware.RequireRoles(promotionHandler.RestorePromotion, managerRoles...))          // POST: Restaurar promoción eliminada
+	mux.HandleFunc("/api/v1/promotions/purge", middleware.RequireRoles(promotionHandler.PurgePromotions, models.RoleAdmin))            // POST: Purgar promociones eliminadas fuera del periodo de retencion
+	mux.HandleFunc("/api/v1/promotions/import", middleware.RequireRoles(promotionHandler.ImportPromotions, marketingRoles...))         // POST: Importar promociones en borrador desde CSV o JSON (format, dry_run)
+	mux.HandleFunc("/api/v1/promotions/export", middleware.RequireRoles(promotionHandler.ExportPromotions, marketingRoles...))         // GET: Exportar promociones en CSV o JSON (format)
+	mux.HandleFunc("/api/v1/promotions/usages", middleware.RequireRoles(promotionHandler.GetUsageHistory, customerAndStaffRoles...))   // GET: Historial de consumos del cliente autenticado o, para el personal, del user_id indicado (paginado por cursor)
+	mux.HandleFunc("/api/v1/promotions/consume", middleware.RequireRoles(promotionHandler.ConsumePromotion, staffRoles...))            // POST: Consumir en caja una o varias promociones combinables (promotion_id separados por comas, cesta opcional)
+	mux.HandleFunc("/api/v1/promotions/best_combination", middleware.RequireRoles(promotionHandler.GetBestCombination, staffRoles...)) // POST: Mejor combinacion de promociones del usuario para una cesta
+	mux.HandleFunc("/api/v1/promotions/usages/void", middleware.RequireRoles(promotionHandler.VoidUsage, staffRoles...))               // POST: Anular un consumo dentro del plazo permitido (motivo obligatorio)
+	mux.HandleFunc("/api/v1/promotions/image", middleware.RequireRoles(promotionImageHandler.UploadImage, marketingRoles...))          // POST: Subir imagen (multipart, campo image)
+	mux.HandleFunc("/api/v1/promotions/image/delete", middleware.RequireRoles(promotionImageHandler.DeleteImages, marketingRoles...))  // DELETE: Quitar imagen
+
+	// Imagenes de las promociones guardadas en el almacenamiento local
+	mux.Handle("/media/", http.StripPrefix("/media/", handlers.MediaFileServer(config.Vars.MediaDir)))
+
+	// Rutas para canje de promociones con QR en tienda: el cliente obtiene el token y el personal lo escanea
+	mux.HandleFunc("/api/v1/promotions/redemption_token", middleware.RequireRoles(redemptionHandler.IssueToken, models.RoleCustomer)) // POST: Obtener token de canje firmado
+	mux.HandleFunc("/api/v1/promotions/redemption_qr", middleware.RequireRoles(redemptionHandler.GetQRCode, models.RoleCustomer))     // GET: QR del token en PNG o SVG
+	mux.HandleFunc("/api/v1/promotions/redeem", middleware.RequireRoles(redemptionHandler.RedeemToken, staffRoles...))                // POST: Validar token escaneado y consumir
+
+	// Rutas para vales personales de promociones
+	mux.HandleFunc("/api/v1/vouchers/issue", middleware.RequireRoles(voucherHandler.IssueVoucher, marketingRoles...))     // POST: Emitir vale personal de una promocion para un usuario
+	mux.HandleFunc("/api/v1/vouchers/wallet", middleware.RequireRoles(voucherHandler.GetWallet, models.RoleCustomer))     // GET: Vales del usuario (status opcional)
+	mux.HandleFunc("/api/v1/vouchers/view", middleware.RequireRoles(voucherHandler.ViewVoucher, models.RoleCustomer))     // POST: Marcar vale como visto
+	mux.HandleFunc("/api/v1/vouchers/redeem", middleware.RequireRoles(voucherHandler.RedeemVoucher, models.RoleCustomer)) // POST: Canjear vale
+
+	// Rutas para experimentos A/B entre variantes de una promocion
+	mux.HandleFunc("/api/v1/experiments/create", middleware.RequireRoles(experimentHandler.CreateExperiment, marketingRoles...))    // POST: Crear y poner en marcha un experimento
+	mux.HandleFunc("/api/v1/experiments", middleware.RequireRoles(experimentHandler.GetExperiments, marketingRoles...))             // GET: Listar experimentos
+	mux.HandleFunc("/api/v1/experiments/stop", middleware.RequireRoles(experimentHandler.StopExperiment, marketingRoles...))        // POST: Detener experimento
+	mux.HandleFunc("/api/v1/experiments/report", middleware.RequireRoles(experimentHandler.GetExperimentReport, marketingRoles...)) // GET: Vistas y conversion por variante
+
+	// Rutas de segmentos de clientes
+	mux.HandleFunc("/api/v1/segments/create", middleware.RequireRoles(segmentHandler.CreateSegment, marketingRoles...)) // POST: Crear segmento
+	mux.HandleFunc("/api/v1/segments", middleware.RequireRoles(segmentHandler.GetSegments, marketingRoles...))          // GET: Listar segmentos
+	mux.HandleFunc("/api/v1/segments/delete", middleware.RequireRoles(segmentHandler.DeleteSegment, marketingRoles...)) // DELETE: Eliminar segmento sin promociones
+
+	// Rutas para codigos de cupon
+	mux.HandleFunc("/api/v1/coupons/batches/generate", middleware.RequireRoles(couponHandler.GenerateBatch, marketingRoles...)) // POST: Generar lote de codigos
+	mux.HandleFunc("/api/v1/coupons/batches/import", middleware.RequireRoles(couponHandler.ImportBatch, marketingRoles...))     // POST: Importar lote de codigos
+	mux.HandleFunc("/api/v1/coupons/batches/export", middleware.RequireRoles(couponHandler.ExportBatch, marketingRoles...))     // GET: Exportar lote en CSV
+	mux.HandleFunc("/api/v1/coupons/redeem", middleware.RequireRoles(couponHandler.RedeemCode, models.RoleCustomer))            // POST: Canjear codigo
 
 	// Ruta para acumulación de puntos
-	mux.HandleFunc("/api/v1/accumulate_points", pointsHandler.AccumulatePoints)
+	mux.HandleFunc("/api/v1/accumulate_points", middleware.RequireRoles(pointsHandler.AccumulatePoints, staffRoles...))             // POST: Acumular puntos de una compra (personal)
+	mux.HandleFunc("/api/v1/points/transactions", middleware.RequireRoles(pointsHandler.GetTransactions, customerAndStaffRoles...)) // GET: Movimientos de puntos del cliente autenticado o, para el personal, del user_id indicado (paginado por cursor)
+
+	// Rutas para la revision de acumulaciones sospechosas
+	mux.HandleFunc("/api/v1/fraud/alerts", middleware.RequireRoles(fraudHandler.GetAlerts, staffRoles...))          // GET: Cola de revision de alertas
+	mux.HandleFunc("/api/v1/fraud/alerts/review", middleware.RequireRoles(fraudHandler.ReviewAlert, staffRoles...)) // POST: Aprobar o rechazar una alerta
+
+	// Rutas para reclamacion de puntos con ticket
+	mux.HandleFunc("/api/v1/pos/transactions", middleware.RequireAPIKey(receiptHandler.SubmitPosTransaction, config.Vars.PosAPIKey)) // POST: Registrar ticket desde el TPV (cabecera X-API-Key)
+	mux.HandleFunc("/api/v1/receipts/claim", middleware.RequireRoles(receiptHandler.ClaimReceipt, models.RoleCustomer))              // POST: Reclamar puntos de un ticket
+	mux.HandleFunc("/api/v1/receipts/pending", middleware.RequireRoles(receiptHandler.GetPendingClaims, staffRoles...))              // GET: Reclamaciones pendientes de revision
+	mux.HandleFunc("/api/v1/receipts/review", middleware.RequireRoles(receiptHandler.ReviewClaim, staffRoles...))                    // POST: Aprobar o rechazar una reclamacion
+
+	// Rutas para el ranking de clientes
+	mux.HandleFunc("/api/v1/leaderboard", leaderboardHandler.GetLeaderboard)                                                             // GET: Ranking mensual, opcionalmente por tienda
+	mux.HandleFunc("/api/v1/leaderboard/opt_out", middleware.RequireRoles(leaderboardHandler.SetLeaderboardOptOut, models.RoleCustomer)) // POST: Salir o volver al ranking
+
+	// Rutas para retos e insignias
+	mux.HandleFunc("/api/v1/challenges/create", middleware.RequireRoles(challengeHandler.CreateChallenge, marketingRoles...))        // POST: Crear reto
+	mux.HandleFunc("/api/v1/challenges/user", middleware.RequireRoles(challengeHandler.GetUserChallenges, customerAndStaffRoles...)) // GET: Retos disponibles, en curso y completados del usuario
+
+	// Rutas para tarjetas de sellos
+	mux.HandleFunc("/api/v1/stamp_cards/create", middleware.RequireRoles(stampCardHandler.CreateStampCard, marketingRoles...))        // POST: Crear programa de sellos
+	mux.HandleFunc("/api/v1/stamp_cards/active", stampCardHandler.GetActiveStampCards)                                                // GET: Programas de sellos activos
+	mux.HandleFunc("/api/v1/stamp_cards/user", middleware.RequireRoles(stampCardHandler.GetUserStampCards, customerAndStaffRoles...)) // GET: Tarjetas y recompensas del usuario
+	mux.HandleFunc("/api/v1/stamp_cards/stamp", middleware.RequireRoles(stampCardHandler.AddStamps, staffRoles...))                   // POST: Sellar tarjetas (personal, maximo 20 sellos por llamada)
+
+	// Publicar cada minuto las promociones programadas cuya fecha de inicio ya ha llegado y
+	// caducar los vales personales vencidos
+	go func() {
+		for now := range time.Tick(time.Minute) {
+			if err := promotionService.PublishScheduledPromotions(now); err != nil {
+				log.Printf("Error publishing scheduled promotions: %v", err)
+			}
+			if err := voucherService.ExpireVouchers(now); err != nil {
+				log.Printf("Error expiring vouchers: %v", err)
+			}
+		}
+	}()
 
 	http.ListenAndServe(":8080", mux)
 }
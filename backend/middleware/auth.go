@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"fidelity-client-app/config"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
+// RequireRoles valida el JWT de la cabecera Authorization y solo deja pasar a los usuarios
+// con alguno de los roles indicados. Los claims quedan disponibles en el contexto de la solicitud
+func RequireRoles(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Obtenemos el token de la cabecera `Authorization: Bearer <token>`
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			http.Error(w, "token de acceso obligatorio", http.StatusUnauthorized)
+			return
+		}
+
+		token, err := jwt.Parse(strings.TrimPrefix(header, "Bearer "), func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(config.Vars.JwtKey), nil
+		})
+		if err != nil || !token.Valid {
+			http.Error(w, "token de acceso no valido", http.StatusUnauthorized)
+			return
+		}
+
+		// Los tokens de canje de promociones tambien estan firmados con la misma clave
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || claims["typ"] != nil {
+			http.Error(w, "token de acceso no valido", http.StatusUnauthorized)
+			return
+		}
+
+		role, _ := claims["role"].(string)
+		allowed := false
+		for _, r := range roles {
+			if role == r {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			http.Error(w, "no tiene permisos para realizar esta accion", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+	}
+}
+
+// RequireAPIKey solo deja pasar las solicitudes de integraciones (ej. TPV) que envian la clave
+// indicada en la cabecera X-API-Key. Si la clave no esta configurada se rechazan todas
+func RequireAPIKey(next http.HandlerFunc, key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provided := r.Header.Get("X-API-Key")
+		if key == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+			http.Error(w, "clave de integracion no valida", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// UserID devuelve el id del usuario autenticado en la solicitud
+func UserID(r *http.Request) string {
+	claims, _ := r.Context().Value(claimsKey).(jwt.MapClaims)
+	userID, _ := claims["user_id"].(string)
+	return userID
+}
+
+// Role devuelve el rol del usuario autenticado en la solicitud
+func Role(r *http.Request) string {
+	claims, _ := r.Context().Value(claimsKey).(jwt.MapClaims)
+	role, _ := claims["role"].(string)
+	return role
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Challenge define un reto que el usuario puede completar con sus compras
+type Challenge struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	Title       string `gorm:"size:50;not null" json:"title"`
+	Description string `gorm:"size:250" json:"description"`
+	Type        string `gorm:"size:20;not null" json:"type"` // visits | distinct_products | spend | weekly_streak
+	Target      int    `gorm:"not null" json:"target"`
+	WindowDays  int    `json:"window_days,omitempty"` // Ventana movil en dias para cumplir el reto (0 = desde el inicio)
+	Badge       string `gorm:"size:50" json:"badge,omitempty"`
+	BonusPoints int    `json:"bonus_points,omitempty"`
+	StartDate   string `json:"start_date"`         // Formato esperado: YYYY-MM-DD
+	EndDate     string `json:"end_date,omitempty"` // Formato esperado: YYYY-MM-DD
+}
+
+// UserChallenge guarda el progreso de un usuario en un reto
+type UserChallenge struct {
+	ID          string     `gorm:"primaryKey" json:"id"`
+	UserID      string     `gorm:"not null;index" json:"user_id"`
+	ChallengeID string     `gorm:"not null;index" json:"challenge_id"`
+	Progress    int        `gorm:"not null" json:"progress"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// UserBadge es la insignia obtenida por un usuario al completar un reto
+type UserBadge struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	UserID      string    `gorm:"not null;index" json:"user_id"`
+	ChallengeID string    `gorm:"not null" json:"challenge_id"`
+	Badge       string    `gorm:"size:50;not null" json:"badge"`
+	AwardedAt   time.Time `gorm:"not null" json:"awarded_at"`
+}
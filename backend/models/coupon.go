@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// CouponBatch es un lote de codigos de cupon asociados a una promocion
+type CouponBatch struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	PromotionID string    `gorm:"not null;index" json:"promotion_id"`
+	Name        string    `gorm:"size:50;not null" json:"name"`
+	Source      string    `gorm:"size:20;not null" json:"source"` // generated | imported
+	Prefix      string    `gorm:"size:10" json:"prefix,omitempty"`
+	Length      int       `json:"length,omitempty"`
+	Alphabet    string    `gorm:"size:64" json:"alphabet,omitempty"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+	MaxUses     int       `gorm:"not null" json:"max_uses"` // Usos permitidos por codigo (1 = un solo uso, 0 = ilimitado)
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+}
+
+// CouponCode es un codigo canjeable por una promocion
+type CouponCode struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	BatchID     string    `gorm:"not null;index" json:"batch_id"`
+	PromotionID string    `gorm:"not null;index" json:"promotion_id"`
+	Code        string    `gorm:"size:40;not null;uniqueIndex" json:"code"`
+	MaxUses     int       `gorm:"not null" json:"max_uses"`
+	Uses        int       `gorm:"not null;default:0" json:"uses"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateFormat es el formato con el que se intercambian las fechas en la API
+const DateFormat = "2006-01-02"
+
+// ErrInvalidDate se devuelve cuando una fecha no sigue el formato `YYYY-MM-DD`
+var ErrInvalidDate = errors.New("el formato de fecha debe ser YYYY-MM-DD")
+
+// Date es una fecha sin hora que se guarda como columna `date` y se serializa como `YYYY-MM-DD`
+type Date struct {
+	time.Time
+}
+
+// NewDate devuelve la fecha de calendario del instante indicado en su propia zona horaria
+func NewDate(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate interpreta una fecha en formato `YYYY-MM-DD`
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateFormat, value)
+	if err != nil {
+		return Date{}, ErrInvalidDate
+	}
+	return Date{t}, nil
+}
+
+// String devuelve la fecha en formato `YYYY-MM-DD`
+func (d Date) String() string {
+	return d.Format(DateFormat)
+}
+
+// MarshalJSON serializa la fecha como `YYYY-MM-DD`
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON acepta `YYYY-MM-DD` y trata la cadena vacia como fecha sin informar
+func (d *Date) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value guarda la fecha en la base de datos
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
+
+// Scan lee la fecha de la base de datos
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = NewDate(v)
+	case string:
+		return d.scanString(v)
+	case []byte:
+		return d.scanString(string(v))
+	default:
+		return fmt.Errorf("no se puede convertir %T a Date", value)
+	}
+	return nil
+}
+
+func (d *Date) scanString(value string) error {
+	if value == "" {
+		*d = Date{}
+		return nil
+	}
+	// Las columnas `date` pueden llegar con la parte horaria segun el driver
+	if len(value) > len(DateFormat) {
+		value = value[:len(DateFormat)]
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// GormDataType indica a GORM que la columna es de tipo `date`
+func (Date) GormDataType() string {
+	return "date"
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDateJSON(t *testing.T) {
+	marshal := []struct {
+		name string
+		date Date
+		want string
+	}{
+		{"fecha vacia", Date{}, `""`},
+		{"fecha", Date{time.Date(2026, time.March, 29, 0, 0, 0, 0, time.UTC)}, `"2026-03-29"`},
+	}
+	for _, tt := range marshal {
+		t.Run("marshal "+tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.date)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json.Marshal = %s, se esperaba %s", got, tt.want)
+			}
+		})
+	}
+
+	unmarshal := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"fecha", `"2026-10-25"`, "2026-10-25", false},
+		{"cadena vacia", `""`, "", false},
+		{"null", `null`, "", false},
+		{"dia inexistente", `"2026-02-30"`, "", true},
+		{"otro formato", `"25/10/2026"`, "", true},
+		{"con hora", `"2026-10-25T10:00:00Z"`, "", true},
+	}
+	for _, tt := range unmarshal {
+		t.Run("unmarshal "+tt.name, func(t *testing.T) {
+			var d Date
+			err := json.Unmarshal([]byte(tt.input), &d)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidDate) {
+					t.Fatalf("se esperaba ErrInvalidDate, se obtuvo %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			got := ""
+			if !d.IsZero() {
+				got = d.String()
+			}
+			if got != tt.want {
+				t.Errorf("json.Unmarshal(%s) = %q, se esperaba %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateScan(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("cargando Europe/Madrid: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"nulo", nil, "", false},
+		{"cadena", "2026-03-29", "2026-03-29", false},
+		{"bytes con hora", []byte("2026-03-29T00:00:00Z"), "2026-03-29", false},
+		{"cadena vacia", "", "", false},
+		{"time a medianoche en Madrid", time.Date(2026, time.March, 29, 0, 0, 0, 0, madrid), "2026-03-29", false},
+		{"time 23:59 en Madrid tras el cambio de hora", time.Date(2026, time.October, 25, 23, 59, 0, 0, madrid), "2026-10-25", false},
+		{"cadena no valida", "29-03-2026", "", true},
+		{"tipo no soportado", 20260329, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Date
+			err := d.Scan(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("se esperaba un error al leer %v", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			got := ""
+			if !d.IsZero() {
+				got = d.String()
+			}
+			if got != tt.want {
+				t.Errorf("Scan(%v) = %q, se esperaba %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Experiment es una prueba A/B entre varias versiones de una oferta. Cada variante es una promocion
+// distinta y cada usuario ve siempre la misma variante mientras el experimento esta en marcha
+type Experiment struct {
+	ID          string              `gorm:"primaryKey" json:"id"`
+	Name        string              `gorm:"size:50;not null;unique" json:"name"`
+	Description string              `gorm:"size:250" json:"description"`
+	Status      string              `gorm:"size:10;not null;index" json:"status"` // running | stopped
+	CreatedBy   string              `gorm:"size:36" json:"created_by,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	StoppedAt   *time.Time          `json:"stopped_at,omitempty"`
+	Variants    []ExperimentVariant `gorm:"foreignKey:ExperimentID" json:"variants"`
+}
+
+// ExperimentVariant es una de las versiones de la oferta que se prueban en un experimento
+type ExperimentVariant struct {
+	ID           string `gorm:"primaryKey" json:"id"`
+	ExperimentID string `gorm:"size:36;not null;index" json:"experiment_id"`
+	Name         string `gorm:"size:20;not null" json:"name"`               // Nombre de la variante (A, B...)
+	PromotionID  string `gorm:"size:36;not null;index" json:"promotion_id"` // Promocion que se muestra a los usuarios de la variante
+	Weight       int    `gorm:"not null;default:1" json:"weight"`           // Peso relativo en el reparto de usuarios
+}
+
+// ExperimentExposure registra la primera vez que se muestra a un usuario su variante del experimento
+type ExperimentExposure struct {
+	ExperimentID string    `gorm:"primaryKey;size:36" json:"experiment_id"`
+	UserID       string    `gorm:"primaryKey;size:36" json:"user_id"`
+	VariantID    string    `gorm:"size:36;not null;index" json:"variant_id"`
+	ExposedAt    time.Time `gorm:"not null" json:"exposed_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// FraudAlert es una acumulacion de puntos sospechosa pendiente de revision
+type FraudAlert struct {
+	ID            string     `gorm:"primaryKey" json:"id"`
+	TransactionID string     `gorm:"not null;index" json:"transaction_id"`
+	UserID        string     `gorm:"not null;index" json:"user_id"`
+	Rules         string     `gorm:"size:250;not null" json:"rules"`       // Reglas incumplidas separadas por comas
+	Action        string     `gorm:"size:20;not null" json:"action"`       // flag | hold
+	Status        string     `gorm:"size:20;not null;index" json:"status"` // open | approved | rejected
+	Note          string     `gorm:"size:250" json:"note,omitempty"`
+	CreatedAt     time.Time  `gorm:"not null" json:"created_at"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy    string     `gorm:"size:36" json:"reviewed_by,omitempty"` // Usuario que reviso la alerta
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PointTransaction registra cada movimiento de puntos de un usuario
+type PointTransaction struct {
+	ID             string    `gorm:"primaryKey" json:"id"`
+	UserID         string    `gorm:"not null;index" json:"user_id"`
+	Type           string    `gorm:"size:20;not null" json:"type"`                     // purchase | bonus | reversal
+	Status         string    `gorm:"size:20;not null;default:completed" json:"status"` // completed | held | rejected
+	PurchaseAmount float64   `json:"purchase_amount"`
+	Points         int       `gorm:"not null" json:"points"`
+	StoreID        string    `gorm:"size:36;index" json:"store_id,omitempty"`
+	StaffID        string    `gorm:"size:36;index" json:"staff_id,omitempty"` // Empleado que registra la compra
+	Products       string    `gorm:"size:500" json:"products,omitempty"`      // Productos separados por comas
+	Reference      string    `gorm:"size:100" json:"reference,omitempty"`
+	CreatedAt      time.Time `gorm:"not null;index" json:"created_at"`
+}
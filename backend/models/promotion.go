@@ -1,10 +1,71 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type Promotion struct {
 	ID            string `gorm:"primaryKey" json:"id"`
 	Title         string `gorm:"size:50;not null" json:"title"`
 	Description   string `gorm:"size:250" json:"description"`
 	LevelRequired int    `gorm:"not null" json:"level_required"`
-	StartDate     string `json:"start_date"`         // Formato esperado: YYYY-MM-DD
-	EndDate       string `json:"end_date,omitempty"` // Formato esperado: YYYY-MM-DD
+	StartDate     Date   `gorm:"not null" json:"start_date"` // Formato esperado: YYYY-MM-DD
+	EndDate       *Date  `json:"end_date,omitempty"`         // Formato esperado: YYYY-MM-DD (nulo = sin fecha de fin)
+
+	// Ciclo de vida: draft | in_review | scheduled | published | paused | archived. Solo las
+	// promociones programadas o publicadas son visibles para los clientes
+	Status          string     `gorm:"size:20;not null;default:draft;index" json:"status"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	StatusChangedBy string     `gorm:"size:36" json:"status_changed_by,omitempty"` // Usuario que hizo el ultimo cambio de estado
+
+	// Version actual de las condiciones, se incrementa con cada modificacion (ver PromotionRevision)
+	Version int `gorm:"not null;default:0" json:"version"`
+
+	// Fecha de borrado logico. Las promociones eliminadas conservan su historial de usos y se pueden restaurar
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Beneficio que otorga la promocion
+	BenefitType     string  `gorm:"size:20" json:"benefit_type,omitempty"` // percentage | fixed_amount | free_product | buy_x_get_y | points_bonus
+	DiscountPercent float64 `json:"discount_percent,omitempty"`            // percentage: porcentaje de descuento sobre la cesta
+	DiscountAmount  float64 `json:"discount_amount,omitempty"`             // fixed_amount: importe a descontar
+	Currency        string  `gorm:"size:3" json:"currency,omitempty"`      // fixed_amount: moneda ISO 4217 del importe
+	Product         string  `gorm:"size:50" json:"product,omitempty"`      // free_product y buy_x_get_y: producto al que se aplica
+	BuyQuantity     int     `json:"buy_quantity,omitempty"`                // buy_x_get_y: unidades a comprar
+	GetQuantity     int     `json:"get_quantity,omitempty"`                // buy_x_get_y: unidades gratis
+	BonusPoints     int     `json:"bonus_points,omitempty"`                // points_bonus: puntos extra
+
+	// Reglas de combinacion con otras promociones en la misma compra
+	ExclusivityGroup string `gorm:"size:30;index" json:"exclusivity_group,omitempty"` // Las promociones del mismo grupo no se pueden combinar entre si
+	Exclusive        bool   `gorm:"not null;default:false" json:"exclusive"`          // No se puede combinar con ninguna otra promocion
+	StackingPriority int    `gorm:"not null;default:0" json:"stacking_priority"`      // Orden de aplicacion en la cesta (menor = antes)
+
+	// Limites de uso de la promocion
+	MaxRedemptions  int    `gorm:"not null;default:0" json:"max_redemptions,omitempty"` // Usos totales permitidos (0 = ilimitado)
+	RedemptionCount int    `gorm:"not null;default:0" json:"redemption_count"`          // Usos totales realizados
+	PerUserLimit    int    `gorm:"not null;default:1" json:"per_user_limit"`            // Usos permitidos por usuario en cada periodo
+	PerUserPeriod   string `gorm:"size:10" json:"per_user_period,omitempty"`            // Periodo del limite por usuario: day | week (vacio = toda la promocion)
+	Remaining       *int   `gorm:"-" json:"remaining,omitempty"`                        // Usos totales restantes, solo si hay limite global
+
+	// Franjas horarias recurrentes en la zona horaria del restaurante (vacio = todo el dia)
+	Schedules []PromotionSchedule `gorm:"foreignKey:PromotionID" json:"schedules,omitempty"`
+
+	// Segmentos de clientes a los que va dirigida, basta con pertenecer a uno (vacio = todos los clientes)
+	Segments []PromotionSegment `gorm:"foreignKey:PromotionID" json:"segments,omitempty"`
+
+	// Imagen principal y miniaturas de la promocion (se suben con su propio endpoint)
+	Images []PromotionImage `gorm:"foreignKey:PromotionID" json:"images,omitempty"`
+}
+
+// AfterFind calcula los usos restantes de la promocion al leerla de la base de datos
+func (p *Promotion) AfterFind(tx *gorm.DB) error {
+	if p.MaxRedemptions > 0 {
+		remaining := p.MaxRedemptions - p.RedemptionCount
+		if remaining < 0 {
+			remaining = 0
+		}
+		p.Remaining = &remaining
+	}
+	return nil
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PromotionImage es una de las versiones redimensionadas de la imagen de una promocion
+type PromotionImage struct {
+	ID          string    `gorm:"primaryKey" json:"-"`
+	PromotionID string    `gorm:"not null;index" json:"-"`
+	Variant     string    `gorm:"size:20;not null" json:"variant"` // hero | thumbnail_large | thumbnail_medium | thumbnail_small
+	StorageKey  string    `gorm:"size:200;not null" json:"-"`      // Ruta del fichero en el almacenamiento
+	URL         string    `gorm:"size:300;not null" json:"url"`
+	ContentType string    `gorm:"size:30;not null" json:"content_type"`
+	Width       int       `gorm:"not null" json:"width"`
+	Height      int       `gorm:"not null" json:"height"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+}
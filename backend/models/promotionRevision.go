@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PromotionRevision guarda una copia de las condiciones de una promocion cada vez que se crea o modifica
+type PromotionRevision struct {
+	ID          string    `gorm:"primaryKey" json:"id"`
+	PromotionID string    `gorm:"not null;uniqueIndex:idx_promotion_revision" json:"promotion_id"`
+	Version     int       `gorm:"not null;uniqueIndex:idx_promotion_revision" json:"version"`
+	Snapshot    string    `gorm:"type:text;not null" json:"-"`        // Promocion serializada en JSON en esta version
+	AuthorID    string    `gorm:"size:36" json:"author_id,omitempty"` // Usuario que hizo el cambio
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// PromotionSchedule es una franja horaria recurrente en la que la promocion esta disponible
+type PromotionSchedule struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	PromotionID string `gorm:"not null;index" json:"promotion_id"`
+	Weekdays    string `gorm:"size:13;not null" json:"weekdays"`  // Dias ISO separados por comas: 1 = lunes ... 7 = domingo
+	StartTime   string `gorm:"size:5;not null" json:"start_time"` // Formato esperado: HH:MM
+	EndTime     string `gorm:"size:5;not null" json:"end_time"`   // Formato esperado: HH:MM (si es menor que StartTime, termina al dia siguiente)
+}
@@ -3,8 +3,22 @@ package models
 import "time"
 
 type PromotionUsage struct {
-	ID          string    `gorm:"primaryKey"`
-	UserID      string    `gorm:"not null"`
-	PromotionID string    `gorm:"not null"`
-	ConsumedAt  time.Time `gorm:"not null"`
+	ID           string    `gorm:"primaryKey" json:"id"`
+	UserID       string    `gorm:"not null;index:idx_usage_user_consumed,priority:1" json:"user_id"`
+	PromotionID  string    `gorm:"not null" json:"promotion_id"`
+	ConsumedAt   time.Time `gorm:"not null;index:idx_usage_user_consumed,priority:2" json:"consumed_at"`
+	CouponCodeID string    `gorm:"size:36" json:"coupon_code_id,omitempty"` // Cupon con el que se canjeo la promocion, si lo hay
+	VoucherID    string    `gorm:"size:36" json:"voucher_id,omitempty"`     // Vale personal con el que se canjeo la promocion, si lo hay
+
+	PromotionRevisionID string `gorm:"size:36;index" json:"promotion_revision_id,omitempty"` // Version de la promocion vigente al consumirla
+	StampRewardID       string `gorm:"size:36" json:"stamp_reward_id,omitempty"`             // Recompensa de sellos canjeada, si lo es
+	BonusTransactionID  string `gorm:"size:36" json:"bonus_transaction_id,omitempty"`        // Movimiento de los puntos extra otorgados, si los hay
+
+	// Anulacion del consumo por el personal. Los consumos anulados no cuentan para los limites de uso
+	VoidedAt   *time.Time `json:"voided_at,omitempty"`
+	VoidedBy   string     `gorm:"size:36" json:"voided_by,omitempty"`
+	VoidReason string     `gorm:"size:250" json:"void_reason,omitempty"`
+
+	// Puntos extra que no se pudieron retirar al anular porque el cliente ya los habia gastado
+	PointsShortfall int `gorm:"not null;default:0" json:"points_shortfall,omitempty"`
 }
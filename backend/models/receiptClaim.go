@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PosTransaction es un ticket enviado por el TPV de una tienda
+type PosTransaction struct {
+	ID              string     `gorm:"primaryKey" json:"id"`
+	StoreID         string     `gorm:"size:36;not null;uniqueIndex:idx_pos_receipt" json:"store_id"`
+	ReceiptNumber   string     `gorm:"size:50;not null;uniqueIndex:idx_pos_receipt" json:"receipt_number"`
+	Date            string     `gorm:"size:10;not null" json:"date"` // Formato esperado: YYYY-MM-DD
+	Total           float64    `gorm:"not null" json:"total"`
+	ClaimedByUserID string     `gorm:"size:36" json:"claimed_by_user_id,omitempty"`
+	ClaimedAt       *time.Time `json:"claimed_at,omitempty"`
+}
+
+// ReceiptClaim es la reclamacion de puntos de un cliente para una compra hecha sin la app
+type ReceiptClaim struct {
+	ID            string     `gorm:"primaryKey" json:"id"`
+	UserID        string     `gorm:"size:36;not null;index" json:"user_id"`
+	StoreID       string     `gorm:"size:36;not null;uniqueIndex:idx_claim_receipt,where:status <> 'rejected'" json:"store_id"`
+	ReceiptNumber string     `gorm:"size:50;not null;uniqueIndex:idx_claim_receipt,where:status <> 'rejected'" json:"receipt_number"`
+	Date          string     `gorm:"size:10;not null" json:"date"` // Formato esperado: YYYY-MM-DD
+	Total         float64    `gorm:"not null" json:"total"`
+	Status        string     `gorm:"size:20;not null;index" json:"status"` // pending | approved | rejected
+	Reason        string     `gorm:"size:250" json:"reason,omitempty"`
+	CreatedAt     time.Time  `gorm:"not null" json:"created_at"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy    string     `gorm:"size:36" json:"reviewed_by,omitempty"` // Usuario que reviso la reclamacion
+}
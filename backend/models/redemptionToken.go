@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RedemptionToken es un token firmado de un solo uso para canjear una promocion en tienda
+type RedemptionToken struct {
+	ID          string     `gorm:"primaryKey" json:"id"`
+	UserID      string     `gorm:"size:36;not null;index" json:"user_id"`
+	PromotionID string     `gorm:"not null" json:"promotion_id"`
+	IssuedAt    time.Time  `gorm:"not null" json:"issued_at"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+}
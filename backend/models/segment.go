@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Segment es un grupo de clientes definido por condiciones sobre su perfil y su actividad.
+// Las condiciones vacias o a cero no se aplican
+type Segment struct {
+	ID                string    `gorm:"primaryKey" json:"id"`
+	Name              string    `gorm:"size:50;not null;unique" json:"name"`
+	Description       string    `gorm:"size:250" json:"description"`
+	Genders           string    `gorm:"size:100" json:"genders,omitempty"`   // Sexos separados por comas
+	MinAge            int       `json:"min_age,omitempty"`                   // Edad minima calculada con BirthDate
+	MaxAge            int       `json:"max_age,omitempty"`                   // Edad maxima calculada con BirthDate
+	MinLevel          int       `json:"min_level,omitempty"`                 // Nivel minimo del cliente
+	MaxLevel          int       `json:"max_level,omitempty"`                 // Nivel maximo del cliente
+	VisitedWithinDays int       `json:"visited_within_days,omitempty"`       // Ultima compra hace como mucho N dias
+	InactiveForDays   int       `json:"inactive_for_days,omitempty"`         // Sin compras en los ultimos N dias
+	MinTotalSpend     float64   `json:"min_total_spend,omitempty"`           // Gasto total minimo en compras
+	StoreIDs          string    `gorm:"size:500" json:"store_ids,omitempty"` // Tiendas de registro separadas por comas
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// PromotionSegment asocia una promocion con uno de los segmentos a los que va dirigida
+type PromotionSegment struct {
+	PromotionID string `gorm:"primaryKey;size:36" json:"-"`
+	SegmentID   string `gorm:"primaryKey;size:36;index" json:"segment_id"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// StampCard define un programa de tarjeta de sellos (ej. "9 cafes y el 10 gratis")
+type StampCard struct {
+	ID                string `gorm:"primaryKey" json:"id"`
+	Title             string `gorm:"size:50;not null" json:"title"`
+	Description       string `gorm:"size:250" json:"description"`
+	Product           string `gorm:"size:50" json:"product,omitempty"`  // Producto que otorga sellos
+	Category          string `gorm:"size:50" json:"category,omitempty"` // Categoria que otorga sellos
+	StampsRequired    int    `gorm:"not null" json:"stamps_required"`
+	RewardPromotionID string `gorm:"not null" json:"reward_promotion_id"` // Promocion que se entrega al completar la tarjeta
+	ExpirationDays    int    `json:"expiration_days,omitempty"`           // Dias de validez de una tarjeta desde el primer sello (0 = sin caducidad)
+	StartDate         string `json:"start_date"`                          // Formato esperado: YYYY-MM-DD
+	EndDate           string `json:"end_date,omitempty"`                  // Formato esperado: YYYY-MM-DD
+}
+
+// UserStampCard guarda el progreso de un usuario en un programa de sellos
+type UserStampCard struct {
+	ID          string     `gorm:"primaryKey" json:"id"`
+	UserID      string     `gorm:"not null;index" json:"user_id"`
+	StampCardID string     `gorm:"not null;index" json:"stamp_card_id"`
+	Stamps      int        `gorm:"not null" json:"stamps"`
+	StartedAt   time.Time  `gorm:"not null" json:"started_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// StampReward es la recompensa emitida al completar una tarjeta de sellos
+type StampReward struct {
+	ID              string     `gorm:"primaryKey" json:"id"`
+	UserID          string     `gorm:"not null;index" json:"user_id"`
+	StampCardID     string     `gorm:"not null" json:"stamp_card_id"`
+	UserStampCardID string     `gorm:"not null" json:"user_stamp_card_id"`
+	PromotionID     string     `gorm:"not null;index" json:"promotion_id"`
+	IssuedAt        time.Time  `gorm:"not null" json:"issued_at"`
+	RedeemedAt      *time.Time `json:"redeemed_at,omitempty"`
+}
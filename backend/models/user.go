@@ -1,14 +1,25 @@
 package models
 
+// Roles de usuario
+const (
+	RoleCustomer         = "customer-client"
+	RoleMarketing        = "marketing"         // Prepara promociones y las envia a revision
+	RoleMarketingManager = "marketing-manager" // Aprueba, pausa y archiva promociones
+	RoleStaff            = "staff"             // Personal de tienda, puede anular consumos registrados por error
+	RoleAdmin            = "admin"
+)
+
 type User struct {
-	ID        string `gorm:"size:36;unique;not null;primaryKey"`
-	FirstName string `gorm:"size:25;not null"`
-	LastName  string `gorm:"size:50;not null"`
-	BirthDate string `gorm:"size:10;not null"`
-	Gender    string `gorm:"size:20;not null"`
-	Email     string `gorm:"size:50;not null;unique"`
-	Password  string `gorm:"size:50;not null"`
-	Role      string `gorm:"default:customer-client"`
-	Points    int    `gorm:"default:1"`
-	Level     int    `gorm:"default:1"`
+	ID                string `gorm:"size:36;unique;not null;primaryKey"`
+	FirstName         string `gorm:"size:25;not null"`
+	LastName          string `gorm:"size:50;not null"`
+	BirthDate         string `gorm:"size:10;not null"`
+	Gender            string `gorm:"size:20;not null"`
+	StoreID           string `gorm:"size:36;index"` // Tienda en la que se registro el cliente
+	Email             string `gorm:"size:50;not null;unique"`
+	Password          string `gorm:"size:50;not null"`
+	Role              string `gorm:"default:customer-client"`
+	Points            int    `gorm:"default:1"`
+	Level             int    `gorm:"default:1"`
+	LeaderboardOptOut bool   `gorm:"default:false"` // Excluye al usuario del ranking de clientes
 }
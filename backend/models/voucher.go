@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Voucher es una instancia personal de una promocion emitida para un usuario concreto
+// (vale de disculpa, regalo de cumpleaños...) con su propia caducidad y estado
+type Voucher struct {
+	ID               string     `gorm:"primaryKey" json:"id"`
+	PromotionID      string     `gorm:"not null;index" json:"promotion_id"`
+	UserID           string     `gorm:"size:36;not null;index:idx_voucher_user_status,priority:1" json:"user_id"`
+	Status           string     `gorm:"size:10;not null;index:idx_voucher_user_status,priority:2" json:"status"` // issued | viewed | redeemed | expired
+	Reason           string     `gorm:"size:100" json:"reason,omitempty"`                                        // Motivo de la emision, visible para el cliente
+	IssuedBy         string     `gorm:"size:36" json:"issued_by,omitempty"`                                      // Usuario que emitio el vale
+	IssuedAt         time.Time  `gorm:"not null" json:"issued_at"`
+	ExpiresAt        time.Time  `gorm:"not null" json:"expires_at"`
+	ViewedAt         *time.Time `json:"viewed_at,omitempty"`
+	RedeemedAt       *time.Time `json:"redeemed_at,omitempty"`
+	PromotionUsageID string     `gorm:"size:36" json:"promotion_usage_id,omitempty"` // Uso registrado al canjear el vale
+
+	Promotion *Promotion `gorm:"foreignKey:PromotionID" json:"promotion,omitempty"`
+}
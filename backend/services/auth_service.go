@@ -88,7 +88,7 @@ func (s *AuthService) RegisterNewUser(user *models.User, passConfirm string) err
 	// Generamos el UUID
 	user.ID = uuid.NewString()
 	// Forzamos el rol del usuario
-	user.Role = "customer-client"
+	user.Role = models.RoleCustomer
 
 	// Guardamos al nuevo usuario en la tabla de User
 	if err := s.DB.Save(&user).Error; err != nil {
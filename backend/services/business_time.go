@@ -0,0 +1,36 @@
+package services
+
+import (
+	"fidelity-client-app/config"
+	"fidelity-client-app/models"
+	"time"
+)
+
+// businessLocation devuelve la zona horaria configurada del restaurante
+func businessLocation() *time.Location {
+	if config.Vars.BusinessLocation != nil {
+		return config.Vars.BusinessLocation
+	}
+	return time.Local
+}
+
+// businessToday devuelve la fecha de calendario del restaurante en el instante indicado
+func businessToday(now time.Time) models.Date {
+	return models.NewDate(now.In(businessLocation()))
+}
+
+// isoWeekday devuelve el dia de la semana ISO (1 = lunes ... 7 = domingo)
+func isoWeekday(t time.Time) int {
+	return (int(t.Weekday())+6)%7 + 1
+}
+
+// isPromotionActiveOn indica si la promocion es visible para los clientes y la fecha esta dentro de su periodo de vigencia
+func isPromotionActiveOn(promotion *models.Promotion, today models.Date) bool {
+	if promotion.Status != PromotionScheduled && promotion.Status != PromotionPublished {
+		return false
+	}
+	if promotion.StartDate.After(today.Time) {
+		return false
+	}
+	return promotion.EndDate == nil || !promotion.EndDate.Before(today.Time)
+}
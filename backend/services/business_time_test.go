@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fidelity-client-app/config"
+	"fidelity-client-app/models"
+	"testing"
+	"time"
+)
+
+// useMadrid configura Europe/Madrid como zona horaria del restaurante durante el test
+func useMadrid(t *testing.T) {
+	t.Helper()
+	location, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("cargando Europe/Madrid: %v", err)
+	}
+	previous := config.Vars.BusinessLocation
+	config.Vars.BusinessLocation = location
+	t.Cleanup(func() { config.Vars.BusinessLocation = previous })
+}
+
+// utc construye un instante en UTC
+func utc(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+// date construye una fecha de calendario para los tests
+func date(t *testing.T, value string) models.Date {
+	t.Helper()
+	d, err := models.ParseDate(value)
+	if err != nil {
+		t.Fatalf("fecha %q no valida: %v", value, err)
+	}
+	return d
+}
+
+// En 2026 el horario de verano en Madrid empieza el 29 de marzo (02:00 CET -> 03:00 CEST)
+// y termina el 25 de octubre (03:00 CEST -> 02:00 CET)
+func TestBusinessToday(t *testing.T) {
+	useMadrid(t)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"invierno 23:59", utc(2026, time.January, 15, 22, 59), "2026-01-15"},
+		{"invierno 00:00", utc(2026, time.January, 15, 23, 0), "2026-01-16"},
+		{"verano 23:59", utc(2026, time.July, 1, 21, 59), "2026-07-01"},
+		{"verano 00:00", utc(2026, time.July, 1, 22, 0), "2026-07-02"},
+		{"inicio horario de verano 00:00", utc(2026, time.March, 28, 23, 0), "2026-03-29"},
+		{"inicio horario de verano 03:00", utc(2026, time.March, 29, 1, 0), "2026-03-29"},
+		{"inicio horario de verano 23:59", utc(2026, time.March, 29, 21, 59), "2026-03-29"},
+		{"dia siguiente al inicio 00:00", utc(2026, time.March, 29, 22, 0), "2026-03-30"},
+		{"fin horario de verano 00:00", utc(2026, time.October, 24, 22, 0), "2026-10-25"},
+		{"fin horario de verano 02:30 repetida", utc(2026, time.October, 25, 1, 30), "2026-10-25"},
+		{"fin horario de verano 23:59", utc(2026, time.October, 25, 22, 59), "2026-10-25"},
+		{"dia siguiente al fin 00:00", utc(2026, time.October, 25, 23, 0), "2026-10-26"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := businessToday(tt.now).String(); got != tt.want {
+				t.Errorf("businessToday(%s) = %s, se esperaba %s", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPromotionActiveOn(t *testing.T) {
+	useMadrid(t)
+
+	endDate := date(t, "2026-03-29")
+	oneDay := models.Promotion{Status: PromotionPublished, StartDate: date(t, "2026-03-29"), EndDate: &endDate}
+	openEnded := models.Promotion{Status: PromotionScheduled, StartDate: date(t, "2026-10-25")}
+	draft := oneDay
+	draft.Status = PromotionDraft
+	paused := oneDay
+	paused.Status = PromotionPaused
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		now       time.Time
+		want      bool
+	}{
+		{"antes de empezar 23:59", oneDay, utc(2026, time.March, 28, 22, 59), false},
+		{"primer minuto del dia", oneDay, utc(2026, time.March, 28, 23, 0), true},
+		{"cambio de hora", oneDay, utc(2026, time.March, 29, 1, 0), true},
+		{"ultimo minuto del dia", oneDay, utc(2026, time.March, 29, 21, 59), true},
+		{"despues de terminar 00:00", oneDay, utc(2026, time.March, 29, 22, 0), false},
+		{"sin fecha de fin antes de empezar", openEnded, utc(2026, time.October, 24, 21, 59), false},
+		{"sin fecha de fin primer minuto", openEnded, utc(2026, time.October, 24, 22, 0), true},
+		{"sin fecha de fin meses despues", openEnded, utc(2027, time.March, 1, 12, 0), true},
+		{"borrador", draft, utc(2026, time.March, 29, 12, 0), false},
+		{"pausada", paused, utc(2026, time.March, 29, 12, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPromotionActiveOn(&tt.promotion, businessToday(tt.now)); got != tt.want {
+				t.Errorf("isPromotionActiveOn en %s = %v, se esperaba %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInSchedule(t *testing.T) {
+	useMadrid(t)
+
+	lunch := []models.PromotionSchedule{{Weekdays: "1,2,3,4,5", StartTime: "12:00", EndTime: "16:00"}}
+	lateThursday := []models.PromotionSchedule{{Weekdays: "4", StartTime: "23:00", EndTime: "00:00"}}
+	saturdayNight := []models.PromotionSchedule{{Weekdays: "6", StartTime: "22:00", EndTime: "02:00"}}
+	sundayEarly := []models.PromotionSchedule{{Weekdays: "7", StartTime: "02:00", EndTime: "03:00"}}
+
+	tests := []struct {
+		name      string
+		schedules []models.PromotionSchedule
+		now       time.Time
+		want      bool
+	}{
+		{"sin franjas", nil, utc(2026, time.January, 15, 3, 0), true},
+		{"antes de la franja", lunch, utc(2026, time.January, 15, 10, 59), false},
+		{"inicio de la franja", lunch, utc(2026, time.January, 15, 11, 0), true},
+		{"fin de la franja excluido", lunch, utc(2026, time.January, 15, 15, 0), false},
+		{"dia no incluido", lunch, utc(2026, time.January, 17, 12, 0), false},
+		{"hasta medianoche 23:59", lateThursday, utc(2026, time.January, 15, 22, 59), true},
+		{"hasta medianoche 00:00", lateThursday, utc(2026, time.January, 15, 23, 0), false},
+		{"noche del sabado 22:00 antes del cambio", saturdayNight, utc(2026, time.March, 28, 21, 0), true},
+		{"noche del sabado 01:59 del domingo", saturdayNight, utc(2026, time.March, 29, 0, 59), true},
+		{"noche del sabado 03:00 tras adelantar la hora", saturdayNight, utc(2026, time.March, 29, 1, 0), false},
+		{"domingo 02:30 CEST antes de atrasar la hora", sundayEarly, utc(2026, time.October, 25, 0, 30), true},
+		{"domingo 02:30 CET hora repetida", sundayEarly, utc(2026, time.October, 25, 1, 30), true},
+		{"domingo 03:00 CET", sundayEarly, utc(2026, time.October, 25, 2, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inSchedule(tt.schedules, tt.now); got != tt.want {
+				t.Errorf("inSchedule en %s = %v, se esperaba %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsagePeriodStart(t *testing.T) {
+	useMadrid(t)
+
+	tests := []struct {
+		name   string
+		period string
+		now    time.Time
+		want   time.Time
+	}{
+		{"dia a las 23:30 en Madrid", PeriodDay, utc(2026, time.January, 15, 22, 30), utc(2026, time.January, 14, 23, 0)},
+		{"dia a las 00:30 en Madrid", PeriodDay, utc(2026, time.January, 15, 23, 30), utc(2026, time.January, 15, 23, 0)},
+		{"dia en que se adelanta la hora", PeriodDay, utc(2026, time.March, 29, 10, 0), utc(2026, time.March, 28, 23, 0)},
+		{"dia en que se atrasa la hora", PeriodDay, utc(2026, time.October, 25, 12, 0), utc(2026, time.October, 24, 22, 0)},
+		{"semana el domingo a las 23:30 en Madrid", PeriodWeek, utc(2026, time.October, 18, 21, 30), utc(2026, time.October, 11, 22, 0)},
+		{"semana el lunes a las 00:30 en Madrid", PeriodWeek, utc(2026, time.October, 18, 22, 30), utc(2026, time.October, 18, 22, 0)},
+		{"semana tras atrasar la hora", PeriodWeek, utc(2026, time.October, 27, 10, 0), utc(2026, time.October, 25, 23, 0)},
+		{"sin periodo", "", utc(2026, time.January, 15, 12, 0), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usagePeriodStart(tt.period, tt.now); !got.Equal(tt.want) {
+				t.Errorf("usagePeriodStart(%q, %s) = %s, se esperaba %s", tt.period, tt.now, got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSchedules(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule models.PromotionSchedule
+		want     models.PromotionSchedule
+		wantErr  bool
+	}{
+		{"normaliza dias y horas", models.PromotionSchedule{Weekdays: " 5,1,5 ", StartTime: "9:30", EndTime: "23:00"},
+			models.PromotionSchedule{Weekdays: "5,1", StartTime: "09:30", EndTime: "23:00"}, false},
+		{"todos los dias con espacios", models.PromotionSchedule{Weekdays: "1, 2, 3, 4, 5, 6, 7", StartTime: "22:00", EndTime: "02:00"},
+			models.PromotionSchedule{Weekdays: "1,2,3,4,5,6,7", StartTime: "22:00", EndTime: "02:00"}, false},
+		{"hora demasiado larga", models.PromotionSchedule{Weekdays: "1", StartTime: "09:30:00", EndTime: "10:00"}, models.PromotionSchedule{}, true},
+		{"hora con texto sobrante", models.PromotionSchedule{Weekdays: "1", StartTime: "09:30", EndTime: "10:00 h"}, models.PromotionSchedule{}, true},
+		{"dia fuera de rango", models.PromotionSchedule{Weekdays: "8", StartTime: "09:30", EndTime: "10:00"}, models.PromotionSchedule{}, true},
+		{"misma hora normalizada", models.PromotionSchedule{Weekdays: "1", StartTime: "9:30", EndTime: "09:30"}, models.PromotionSchedule{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedules := []models.PromotionSchedule{tt.schedule}
+			err := validateSchedules(schedules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateSchedules() error = %v, se esperaba error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && schedules[0] != tt.want {
+				t.Errorf("validateSchedules() = %+v, se esperaba %+v", schedules[0], tt.want)
+			}
+		})
+	}
+}
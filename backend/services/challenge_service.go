@@ -0,0 +1,284 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChallengeService struct {
+	DB *gorm.DB
+}
+
+// Tipos de reto soportados
+const (
+	ChallengeVisits           = "visits"
+	ChallengeDistinctProducts = "distinct_products"
+	ChallengeSpend            = "spend"
+	ChallengeWeeklyStreak     = "weekly_streak"
+)
+
+// ChallengeProgress combina un reto con el progreso del usuario
+type ChallengeProgress struct {
+	Challenge   models.Challenge `json:"challenge"`
+	Progress    int              `json:"progress"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+}
+
+// UserChallenges agrupa los retos de un usuario segun su estado
+type UserChallenges struct {
+	Available  []models.Challenge  `json:"available"`
+	InProgress []ChallengeProgress `json:"in_progress"`
+	Completed  []ChallengeProgress `json:"completed"`
+	Badges     []models.UserBadge  `json:"badges"`
+}
+
+// CreateChallenge (logica de negocio para crear un nuevo reto)
+func (s *ChallengeService) CreateChallenge(challenge *models.Challenge) error {
+
+	// Validar que el titulo esta completo
+	if challenge.Title == "" {
+		return errors.New("el titulo es obligatorio")
+	}
+
+	// Validar el tipo de reto
+	switch challenge.Type {
+	case ChallengeVisits, ChallengeDistinctProducts, ChallengeSpend, ChallengeWeeklyStreak:
+	default:
+		return errors.New("el tipo de reto no es valido")
+	}
+
+	if challenge.Target < 1 {
+		return errors.New("el objetivo del reto tiene que ser mayor que cero")
+	}
+	if challenge.WindowDays < 0 {
+		return errors.New("la ventana del reto no puede ser negativa")
+	}
+	if challenge.BonusPoints < 0 {
+		return errors.New("los puntos extra no pueden ser negativos")
+	}
+
+	// Validar que StartDate siga el formato `YYYY-MM-DD`
+	startDate, err := time.Parse(dateFormat, challenge.StartDate)
+	if err != nil {
+		return errors.New("el formato de start_date debe ser YYYY-MM-DD")
+	}
+
+	// Validar que EndDate siga el formato `YYYY-MM-DD` y no sea anterior a StartDate
+	if challenge.EndDate != "" {
+		endDate, err := time.Parse(dateFormat, challenge.EndDate)
+		if err != nil {
+			return errors.New("el formato de end_date debe ser YYYY-MM-DD")
+		}
+		if endDate.Before(startDate) {
+			return errors.New("la fecha de fin no puede ser anterior a la fecha de inicio")
+		}
+	}
+
+	// Generamos el ID del reto
+	challenge.ID = uuid.NewString()
+
+	// Guardar el reto en la base de datos
+	if err := s.DB.Save(challenge).Error; err != nil {
+		return errors.New("error al guardar el reto")
+	}
+
+	return nil
+}
+
+// GetUserChallenges obtiene los retos disponibles, en curso y completados de un usuario
+func (s *ChallengeService) GetUserChallenges(userID string) (*UserChallenges, error) {
+	currentDate := businessToday(time.Now()).String()
+
+	var challenges []models.Challenge
+	if err := s.DB.Find(&challenges).Error; err != nil {
+		return nil, err
+	}
+
+	var userChallenges []models.UserChallenge
+	if err := s.DB.Where("user_id = ?", userID).Find(&userChallenges).Error; err != nil {
+		return nil, err
+	}
+
+	progressByChallenge := make(map[string]models.UserChallenge)
+	for _, uc := range userChallenges {
+		progressByChallenge[uc.ChallengeID] = uc
+	}
+
+	result := &UserChallenges{
+		Available:  []models.Challenge{},
+		InProgress: []ChallengeProgress{},
+		Completed:  []ChallengeProgress{},
+	}
+
+	for _, challenge := range challenges {
+		uc, started := progressByChallenge[challenge.ID]
+
+		// Los retos completados se muestran aunque ya no esten activos
+		if started && uc.CompletedAt != nil {
+			result.Completed = append(result.Completed, ChallengeProgress{Challenge: challenge, Progress: uc.Progress, CompletedAt: uc.CompletedAt})
+			continue
+		}
+
+		if !isActiveOn(challenge.StartDate, challenge.EndDate, currentDate) {
+			continue
+		}
+
+		if started && uc.Progress > 0 {
+			result.InProgress = append(result.InProgress, ChallengeProgress{Challenge: challenge, Progress: uc.Progress})
+		} else {
+			result.Available = append(result.Available, challenge)
+		}
+	}
+
+	if err := s.DB.Where("user_id = ?", userID).Order("awarded_at").Find(&result.Badges).Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// EvaluateChallenges recalcula el progreso del usuario en los retos activos tras un evento de acumulacion.
+// Se ejecuta dentro de la transaccion de acumulacion y suma a user.Points los puntos extra obtenidos.
+func (s *ChallengeService) EvaluateChallenges(tx *gorm.DB, user *models.User, now time.Time) ([]models.Challenge, error) {
+	currentDate := businessToday(now).String()
+
+	var challenges []models.Challenge
+	if err := tx.Where("start_date <= ? AND (end_date IS NULL OR end_date = '' OR end_date >= ?)", currentDate, currentDate).
+		Find(&challenges).Error; err != nil {
+		return nil, errors.New("error al obtener los retos")
+	}
+
+	var completed []models.Challenge
+	for _, challenge := range challenges {
+
+		// Buscamos el progreso del usuario en el reto, o lo inicializamos
+		var uc models.UserChallenge
+		err := tx.Where("user_id = ? AND challenge_id = ?", user.ID, challenge.ID).First(&uc).Error
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("error al obtener el progreso del reto")
+			}
+			uc = models.UserChallenge{ID: uuid.NewString(), UserID: user.ID, ChallengeID: challenge.ID}
+		}
+
+		// Los retos ya completados no se vuelven a evaluar
+		if uc.CompletedAt != nil {
+			continue
+		}
+
+		// Las compras que cuentan son las de la ventana del reto, sin ser anteriores a su inicio
+		since, _ := time.ParseInLocation(dateFormat, challenge.StartDate, businessLocation())
+		if challenge.WindowDays > 0 {
+			if windowStart := now.AddDate(0, 0, -challenge.WindowDays); windowStart.After(since) {
+				since = windowStart
+			}
+		}
+
+		var transactions []models.PointTransaction
+		if err := tx.Where("user_id = ? AND type = ? AND status = ? AND created_at >= ?", user.ID, TransactionPurchase, TransactionCompleted, since).
+			Find(&transactions).Error; err != nil {
+			return nil, errors.New("error al obtener las compras del usuario")
+		}
+
+		uc.Progress = challengeProgress(challenge, transactions, now)
+		uc.UpdatedAt = now
+
+		if uc.Progress >= challenge.Target {
+			completedAt := now
+			uc.CompletedAt = &completedAt
+			completed = append(completed, challenge)
+
+			// Otorgamos la insignia del reto
+			if challenge.Badge != "" {
+				badge := models.UserBadge{
+					ID:          uuid.NewString(),
+					UserID:      user.ID,
+					ChallengeID: challenge.ID,
+					Badge:       challenge.Badge,
+					AwardedAt:   now,
+				}
+				if err := tx.Create(&badge).Error; err != nil {
+					return nil, errors.New("error al otorgar la insignia")
+				}
+			}
+
+			// Otorgamos los puntos extra del reto
+			if challenge.BonusPoints > 0 {
+				bonus := models.PointTransaction{
+					ID:        uuid.NewString(),
+					UserID:    user.ID,
+					Type:      TransactionBonus,
+					Status:    TransactionCompleted,
+					Points:    challenge.BonusPoints,
+					Reference: challenge.ID,
+					CreatedAt: now,
+				}
+				if err := tx.Create(&bonus).Error; err != nil {
+					return nil, errors.New("error al otorgar los puntos extra")
+				}
+				user.Points += challenge.BonusPoints
+			}
+		}
+
+		if err := tx.Save(&uc).Error; err != nil {
+			return nil, errors.New("error al guardar el progreso del reto")
+		}
+	}
+
+	return completed, nil
+}
+
+// challengeProgress calcula el progreso de un reto a partir de las compras del usuario
+func challengeProgress(challenge models.Challenge, transactions []models.PointTransaction, now time.Time) int {
+	switch challenge.Type {
+	case ChallengeVisits:
+		return len(transactions)
+
+	case ChallengeSpend:
+		var total float64
+		for _, t := range transactions {
+			total += t.PurchaseAmount
+		}
+		return int(total)
+
+	case ChallengeDistinctProducts:
+		products := make(map[string]bool)
+		for _, t := range transactions {
+			for _, p := range strings.Split(t.Products, ",") {
+				if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+					products[p] = true
+				}
+			}
+		}
+		return len(products)
+
+	case ChallengeWeeklyStreak:
+		// Semanas consecutivas con al menos una compra, contando hacia atras desde la actual.
+		// Las semanas se cuentan en la zona horaria del restaurante, como las fechas de los retos
+		weeks := make(map[[2]int]bool)
+		for _, t := range transactions {
+			year, week := t.CreatedAt.In(businessLocation()).ISOWeek()
+			weeks[[2]int{year, week}] = true
+		}
+		streak := 0
+		for day := now.In(businessLocation()); ; day = day.AddDate(0, 0, -7) {
+			year, week := day.ISOWeek()
+			if !weeks[[2]int{year, week}] {
+				break
+			}
+			streak++
+		}
+		return streak
+	}
+	return 0
+}
+
+// isActiveOn indica si un periodo `YYYY-MM-DD` contiene la fecha indicada
+func isActiveOn(startDate, endDate, currentDate string) bool {
+	return startDate <= currentDate && (endDate == "" || endDate >= currentDate)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fidelity-client-app/models"
+	"testing"
+	"time"
+)
+
+// El 18 de octubre de 2026 es domingo, a las 22:30 UTC ya es lunes en Madrid
+func TestWeeklyStreakUsesBusinessWeeks(t *testing.T) {
+	useMadrid(t)
+	challenge := models.Challenge{Type: ChallengeWeeklyStreak}
+
+	tests := []struct {
+		name      string
+		purchases []time.Time
+		now       time.Time
+		want      int
+	}{
+		{"compra el lunes a las 00:30 en Madrid cuenta en la semana nueva",
+			[]time.Time{utc(2026, time.October, 12, 12, 0), utc(2026, time.October, 18, 22, 30)},
+			utc(2026, time.October, 19, 10, 0), 2},
+		{"compra el domingo a las 23:30 en Madrid cuenta en la semana anterior",
+			[]time.Time{utc(2026, time.October, 18, 21, 30)},
+			utc(2026, time.October, 19, 10, 0), 0},
+		{"semanas seguidas a traves del cambio de hora",
+			[]time.Time{utc(2026, time.October, 20, 10, 0), utc(2026, time.October, 26, 10, 0)},
+			utc(2026, time.October, 26, 23, 30), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transactions []models.PointTransaction
+			for _, createdAt := range tt.purchases {
+				transactions = append(transactions, models.PointTransaction{CreatedAt: createdAt})
+			}
+			if got := challengeProgress(challenge, transactions, tt.now); got != tt.want {
+				t.Errorf("challengeProgress() = %d, se esperaba %d", got, tt.want)
+			}
+		})
+	}
+}
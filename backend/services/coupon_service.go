@@ -0,0 +1,297 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"fidelity-client-app/models"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CouponService struct {
+	DB               *gorm.DB
+	PromotionService *PromotionService
+}
+
+// Origen de los codigos de un lote
+const (
+	BatchGenerated = "generated"
+	BatchImported  = "imported"
+)
+
+// Valores por defecto de la generacion de codigos. El alfabeto excluye caracteres ambiguos (0/O, 1/I)
+const (
+	defaultCouponAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	defaultCouponLength   = 8
+	maxCouponBatchSize    = 10000
+	maxCouponCodeLength   = 40
+	couponSpaceFactor     = 10 // Codigos posibles por cada codigo pedido
+)
+
+// GenerateBatch genera un lote de codigos aleatorios unicos para una promocion
+func (s *CouponService) GenerateBatch(batch *models.CouponBatch) ([]models.CouponCode, error) {
+
+	// Aplicamos los valores por defecto y validamos la configuracion
+	batch.Prefix = strings.ToUpper(batch.Prefix)
+	batch.Alphabet = strings.ToUpper(batch.Alphabet)
+	if batch.Alphabet == "" {
+		batch.Alphabet = defaultCouponAlphabet
+	}
+	if batch.Length == 0 {
+		batch.Length = defaultCouponLength
+	}
+
+	if batch.Quantity < 1 || batch.Quantity > maxCouponBatchSize {
+		return nil, errors.New("la cantidad de codigos debe estar entre 1 y 10000")
+	}
+	if batch.Length < 4 || len(batch.Prefix)+batch.Length > maxCouponCodeLength {
+		return nil, errors.New("la longitud de los codigos no es valida")
+	}
+	if err := validateAlphabet(batch.Alphabet); err != nil {
+		return nil, err
+	}
+	if strings.Trim(batch.Prefix, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") != "" {
+		return nil, errors.New("el prefijo solo puede contener letras, numeros y guiones")
+	}
+
+	// El espacio de codigos posibles debe ser bastante mayor que la cantidad pedida para que los
+	// codigos aleatorios no se repitan casi siempre
+	if !couponSpaceAllows(len(batch.Alphabet), batch.Length, batch.Quantity*couponSpaceFactor) {
+		return nil, errors.New("no hay suficientes codigos posibles para esa cantidad, aumente la longitud o el alfabeto")
+	}
+
+	// Generamos codigos hasta completar la cantidad, descartando repetidos y ya existentes
+	codes := make(map[string]bool, batch.Quantity)
+	for attempts := 0; len(codes) < batch.Quantity; attempts++ {
+		if attempts > 10 {
+			return nil, errors.New("no se han podido generar suficientes codigos unicos, aumente la longitud")
+		}
+
+		// Limitamos tambien los intentos de cada pasada por si los codigos libres se agotan
+		candidates := make([]string, 0, batch.Quantity-len(codes))
+		for tries := 0; len(candidates) < cap(candidates) && tries < cap(candidates)*couponSpaceFactor; tries++ {
+			code, err := randomCode(batch.Alphabet, batch.Length)
+			if err != nil {
+				return nil, errors.New("error al generar los codigos")
+			}
+			code = batch.Prefix + code
+			if !codes[code] {
+				candidates = append(candidates, code)
+			}
+		}
+
+		var existing []string
+		if err := s.DB.Model(&models.CouponCode{}).Where("code IN ?", candidates).Pluck("code", &existing).Error; err != nil {
+			return nil, errors.New("error al generar los codigos")
+		}
+		taken := make(map[string]bool, len(existing))
+		for _, code := range existing {
+			taken[code] = true
+		}
+		for _, code := range candidates {
+			if !taken[code] {
+				codes[code] = true
+			}
+		}
+	}
+
+	batch.Source = BatchGenerated
+	return s.saveBatch(batch, codes)
+}
+
+// ImportBatch crea un lote a partir de codigos proporcionados por marketing
+func (s *CouponService) ImportBatch(batch *models.CouponBatch, importedCodes []string) ([]models.CouponCode, error) {
+
+	codes := make(map[string]bool, len(importedCodes))
+	for _, code := range importedCodes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if len(code) > maxCouponCodeLength {
+			return nil, errors.New("el codigo " + code + " es demasiado largo")
+		}
+		if codes[code] {
+			return nil, errors.New("el codigo " + code + " esta repetido")
+		}
+		codes[code] = true
+	}
+
+	if len(codes) == 0 || len(codes) > maxCouponBatchSize {
+		return nil, errors.New("la cantidad de codigos debe estar entre 1 y 10000")
+	}
+
+	batch.Source = BatchImported
+	batch.Prefix = ""
+	batch.Length = 0
+	batch.Alphabet = ""
+	batch.Quantity = len(codes)
+	return s.saveBatch(batch, codes)
+}
+
+// saveBatch valida el lote y guarda el lote junto con sus codigos
+func (s *CouponService) saveBatch(batch *models.CouponBatch, codes map[string]bool) ([]models.CouponCode, error) {
+
+	if batch.Name == "" {
+		return nil, errors.New("el nombre del lote es obligatorio")
+	}
+	if batch.MaxUses < 0 {
+		return nil, errors.New("el numero de usos por codigo no puede ser negativo")
+	}
+
+	// Comprobamos que la promocion existe
+	if _, err := s.PromotionService.GetPromotionByID(batch.PromotionID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	batch.ID = uuid.NewString()
+	batch.Quantity = len(codes)
+	batch.CreatedAt = now
+
+	couponCodes := make([]models.CouponCode, 0, len(codes))
+	for code := range codes {
+		couponCodes = append(couponCodes, models.CouponCode{
+			ID:          uuid.NewString(),
+			BatchID:     batch.ID,
+			PromotionID: batch.PromotionID,
+			Code:        code,
+			MaxUses:     batch.MaxUses,
+			CreatedAt:   now,
+		})
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return errors.New("error al guardar el lote")
+		}
+		if err := tx.CreateInBatches(couponCodes, 500).Error; err != nil {
+			return errors.New("error al guardar los codigos, puede que alguno ya exista")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return couponCodes, nil
+}
+
+// RedeemCode canjea un codigo de cupon consumiendo la promocion asociada para el usuario
+func (s *CouponService) RedeemCode(userID, code string) (*models.PromotionUsage, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	var usage *models.PromotionUsage
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// Bloqueamos el codigo para que dos canjes simultaneos no superen sus usos
+		var coupon models.CouponCode
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, "code = ?", code).Error; err != nil {
+			return errors.New("codigo no encontrado")
+		}
+		if coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses {
+			return errors.New("el codigo ya ha sido utilizado")
+		}
+
+		var err error
+		usage, err = s.PromotionService.ConsumePromotionTx(tx, userID, coupon.PromotionID)
+		if err != nil {
+			return err
+		}
+
+		coupon.Uses++
+		if err := tx.Save(&coupon).Error; err != nil {
+			return errors.New("error al canjear el codigo")
+		}
+
+		usage.CouponCodeID = coupon.ID
+		if err := tx.Save(usage).Error; err != nil {
+			return errors.New("error al canjear el codigo")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// ExportBatchCSV escribe en formato CSV los codigos de un lote
+func (s *CouponService) ExportBatchCSV(batchID string, w io.Writer) error {
+	var batch models.CouponBatch
+	if err := s.DB.First(&batch, "id = ?", batchID).Error; err != nil {
+		return errors.New("lote no encontrado")
+	}
+
+	var codes []models.CouponCode
+	if err := s.DB.Where("batch_id = ?", batchID).Order("code").Find(&codes).Error; err != nil {
+		return errors.New("error al obtener los codigos")
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"code", "promotion_id", "max_uses", "uses", "created_at"})
+	for _, code := range codes {
+		writer.Write([]string{
+			code.Code,
+			code.PromotionID,
+			strconv.Itoa(code.MaxUses),
+			strconv.Itoa(code.Uses),
+			code.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// validateAlphabet comprueba que el alfabeto tiene caracteres alfanumericos sin repetir
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("el alfabeto debe tener al menos dos caracteres")
+	}
+	seen := make(map[rune]bool)
+	for _, char := range alphabet {
+		if !strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", char) {
+			return errors.New("el alfabeto solo puede contener letras y numeros")
+		}
+		if seen[char] {
+			return errors.New("el alfabeto no puede tener caracteres repetidos")
+		}
+		seen[char] = true
+	}
+	return nil
+}
+
+// randomCode genera un codigo aleatorio criptograficamente seguro con el alfabeto indicado
+func randomCode(alphabet string, length int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// couponSpaceAllows indica si con el alfabeto y la longitud se pueden formar al menos minimum codigos
+func couponSpaceAllows(alphabetSize, length, minimum int) bool {
+	space := 1
+	for i := 0; i < length; i++ {
+		space *= alphabetSize
+		if space >= minimum {
+			return true
+		}
+	}
+	return space >= minimum
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Tamaño de pagina por defecto y maximo de los listados paginados por cursor
+const (
+	defaultCursorLimit = 20
+	maxCursorLimit     = 100
+)
+
+// pageCursor es la posicion del ultimo elemento devuelto en un listado ordenado por (valor, id)
+type pageCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// encodeCursor genera el cursor opaco que el cliente envia para pedir la pagina siguiente
+func encodeCursor(value, id string) string {
+	data, _ := json.Marshal(pageCursor{Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor interpreta el cursor recibido. Un cursor vacio indica la primera pagina
+func decodeCursor(cursor string) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("el cursor no es valido")
+	}
+	var decoded pageCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID == "" {
+		return nil, errors.New("el cursor no es valido")
+	}
+	return &decoded, nil
+}
+
+// cursorLimit ajusta el tamaño de pagina pedido a los limites permitidos
+func cursorLimit(limit int) int {
+	if limit < 1 {
+		return defaultCursorLimit
+	}
+	if limit > maxCursorLimit {
+		return maxCursorLimit
+	}
+	return limit
+}
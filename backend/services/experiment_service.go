@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Estados de un experimento A/B
+const (
+	ExperimentRunning = "running"
+	ExperimentStopped = "stopped"
+)
+
+type ExperimentService struct {
+	DB *gorm.DB
+}
+
+// VariantReport son las metricas de conversion de una variante del experimento
+type VariantReport struct {
+	VariantID      string  `json:"variant_id"`
+	Name           string  `json:"name"`
+	PromotionID    string  `json:"promotion_id"`
+	Views          int64   `json:"views"`           // Usuarios a los que se ha mostrado la variante
+	Conversions    int64   `json:"conversions"`     // Usuarios que la han consumido despues de verla
+	Consumptions   int64   `json:"consumptions"`    // Consumos totales despues de verla
+	ConversionRate float64 `json:"conversion_rate"` // Conversions / Views
+}
+
+// ExperimentReport es el informe de resultados de un experimento
+type ExperimentReport struct {
+	models.Experiment
+	Results []VariantReport `json:"results"`
+}
+
+// CreateExperiment crea y pone en marcha un experimento con al menos dos variantes
+func (s *ExperimentService) CreateExperiment(experiment *models.Experiment, authorID string) error {
+	experiment.Name = strings.TrimSpace(experiment.Name)
+	if experiment.Name == "" {
+		return errors.New("el nombre del experimento es obligatorio")
+	}
+	if len(experiment.Variants) < 2 {
+		return errors.New("el experimento necesita al menos dos variantes")
+	}
+
+	names := make(map[string]bool)
+	promotionIDs := make([]string, 0, len(experiment.Variants))
+	for i := range experiment.Variants {
+		variant := &experiment.Variants[i]
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" || variant.PromotionID == "" {
+			return errors.New("cada variante necesita un nombre y una promocion")
+		}
+		if names[variant.Name] {
+			return errors.New("los nombres de las variantes no se pueden repetir")
+		}
+		names[variant.Name] = true
+		if contains(promotionIDs, variant.PromotionID) {
+			return errors.New("cada variante debe usar una promocion distinta")
+		}
+		promotionIDs = append(promotionIDs, variant.PromotionID)
+
+		// Sin peso se reparte a partes iguales
+		if variant.Weight < 0 {
+			return errors.New("el peso de las variantes no puede ser negativo")
+		}
+		if variant.Weight == 0 {
+			variant.Weight = 1
+		}
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var found int64
+		if err := tx.Model(&models.Promotion{}).Where("id IN ?", promotionIDs).Count(&found).Error; err != nil {
+			return errors.New("error al crear el experimento")
+		}
+		if int(found) != len(promotionIDs) {
+			return errors.New("promotion not found")
+		}
+
+		// Una promocion solo puede estar en un experimento en marcha a la vez
+		var busy int64
+		if err := tx.Model(&models.ExperimentVariant{}).
+			Joins("JOIN experiments ON experiments.id = experiment_variants.experiment_id").
+			Where("experiment_variants.promotion_id IN ? AND experiments.status = ?", promotionIDs, ExperimentRunning).
+			Count(&busy).Error; err != nil {
+			return errors.New("error al crear el experimento")
+		}
+		if busy > 0 {
+			return errors.New("alguna de las promociones ya forma parte de otro experimento en marcha")
+		}
+
+		experiment.ID = uuid.NewString()
+		experiment.Status = ExperimentRunning
+		experiment.CreatedBy = authorID
+		experiment.CreatedAt = time.Now()
+		experiment.StoppedAt = nil
+		for i := range experiment.Variants {
+			experiment.Variants[i].ID = uuid.NewString()
+			experiment.Variants[i].ExperimentID = experiment.ID
+		}
+
+		if err := tx.Create(experiment).Error; err != nil {
+			return errors.New("ya existe un experimento con ese nombre")
+		}
+		return nil
+	})
+}
+
+// GetExperiments devuelve los experimentos con sus variantes, los mas recientes primero
+func (s *ExperimentService) GetExperiments() ([]models.Experiment, error) {
+	var experiments []models.Experiment
+	if err := s.DB.Preload("Variants").Order("created_at DESC").Find(&experiments).Error; err != nil {
+		return nil, err
+	}
+	return experiments, nil
+}
+
+// StopExperiment detiene el experimento. A partir de entonces todas sus variantes se muestran
+// como promociones normales, por lo que las perdedoras se deben pausar o archivar
+func (s *ExperimentService) StopExperiment(id string) (*models.Experiment, error) {
+	var experiment models.Experiment
+	if err := s.DB.Preload("Variants").First(&experiment, "id = ?", id).Error; err != nil {
+		return nil, errors.New("experimento no encontrado")
+	}
+	if experiment.Status == ExperimentStopped {
+		return nil, errors.New("el experimento ya esta detenido")
+	}
+
+	now := time.Now()
+	experiment.Status = ExperimentStopped
+	experiment.StoppedAt = &now
+	if err := s.DB.Model(&experiment).Select("status", "stopped_at").Updates(&experiment).Error; err != nil {
+		return nil, errors.New("error al detener el experimento")
+	}
+	return &experiment, nil
+}
+
+// GetExperimentReport calcula para cada variante cuantos usuarios la han visto y cuantos la han
+// consumido despues de verla
+func (s *ExperimentService) GetExperimentReport(id string) (*ExperimentReport, error) {
+	var experiment models.Experiment
+	if err := s.DB.Preload("Variants").First(&experiment, "id = ?", id).Error; err != nil {
+		return nil, errors.New("experimento no encontrado")
+	}
+
+	var views []struct {
+		VariantID string
+		Views     int64
+	}
+	if err := s.DB.Model(&models.ExperimentExposure{}).
+		Select("variant_id, COUNT(*) AS views").
+		Where("experiment_id = ?", id).
+		Group("variant_id").
+		Scan(&views).Error; err != nil {
+		return nil, errors.New("error al calcular el informe del experimento")
+	}
+
+	// Solo cuentan los consumos de la promocion de la variante posteriores a la primera vez que se mostro
+	var conversions []struct {
+		VariantID    string
+		Conversions  int64
+		Consumptions int64
+	}
+	if err := s.DB.Table("experiment_exposures AS e").
+		Select("e.variant_id, COUNT(DISTINCT u.user_id) AS conversions, COUNT(u.id) AS consumptions").
+		Joins("JOIN experiment_variants AS v ON v.id = e.variant_id").
+		Joins("JOIN promotion_usages AS u ON u.user_id = e.user_id AND u.promotion_id = v.promotion_id AND u.consumed_at >= e.exposed_at AND u.voided_at IS NULL").
+		Where("e.experiment_id = ?", id).
+		Group("e.variant_id").
+		Scan(&conversions).Error; err != nil {
+		return nil, errors.New("error al calcular el informe del experimento")
+	}
+
+	report := ExperimentReport{Experiment: experiment, Results: []VariantReport{}}
+	for _, variant := range experiment.Variants {
+		result := VariantReport{VariantID: variant.ID, Name: variant.Name, PromotionID: variant.PromotionID}
+		for _, row := range views {
+			if row.VariantID == variant.ID {
+				result.Views = row.Views
+			}
+		}
+		for _, row := range conversions {
+			if row.VariantID == variant.ID {
+				result.Conversions = row.Conversions
+				result.Consumptions = row.Consumptions
+			}
+		}
+		if result.Views > 0 {
+			result.ConversionRate = math.Round(float64(result.Conversions)/float64(result.Views)*10000) / 10000
+		}
+		report.Results = append(report.Results, result)
+	}
+	return &report, nil
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Acciones que se aplican a una acumulacion sospechosa
+const (
+	FraudActionFlag = "flag" // Se acreditan los puntos pero se marca para revision
+	FraudActionHold = "hold" // Se retienen los puntos hasta que el personal la apruebe
+)
+
+// Estados de una alerta de fraude
+const (
+	AlertOpen     = "open"
+	AlertApproved = "approved"
+	AlertRejected = "rejected"
+)
+
+// FraudRules contiene los umbrales de las reglas de deteccion de anomalias
+type FraudRules struct {
+	Window              time.Duration // Ventana sobre la que se calculan las velocidades
+	MaxPurchaseAmount   float64       // Importe a partir del cual se retiene la compra
+	MaxPerUser          int           // Acumulaciones maximas de un usuario en la ventana
+	MaxPerStaff         int           // Acumulaciones maximas registradas por un empleado en la ventana
+	MaxPerStore         int           // Acumulaciones maximas de una tienda en la ventana
+	MaxAccountsPerStaff int           // Cuentas distintas que puede acreditar un empleado en la ventana
+}
+
+// DefaultFraudRules devuelve los umbrales por defecto
+func DefaultFraudRules() FraudRules {
+	return FraudRules{
+		Window:              time.Hour,
+		MaxPurchaseAmount:   500,
+		MaxPerUser:          5,
+		MaxPerStaff:         60,
+		MaxPerStore:         200,
+		MaxAccountsPerStaff: 30,
+	}
+}
+
+type FraudService struct {
+	DB    *gorm.DB
+	Rules FraudRules
+}
+
+// EvaluateAccrual aplica las reglas de anomalias a una compra antes de registrarla.
+// Devuelve la accion a aplicar (vacia si no es sospechosa) y las reglas incumplidas
+func (s *FraudService) EvaluateAccrual(tx *gorm.DB, accrual PointsAccrual, now time.Time) (string, []string, error) {
+	var rules []string
+	action := ""
+
+	// apply registra la regla incumplida quedandose con la accion mas restrictiva
+	apply := func(rule, ruleAction string) {
+		rules = append(rules, rule)
+		if action != FraudActionHold {
+			action = ruleAction
+		}
+	}
+
+	if s.Rules.MaxPurchaseAmount > 0 && accrual.PurchaseAmount > s.Rules.MaxPurchaseAmount {
+		apply("large_amount", FraudActionHold)
+	}
+
+	since := now.Add(-s.Rules.Window)
+	recent := tx.Model(&models.PointTransaction{}).Where("type = ? AND created_at >= ?", TransactionPurchase, since)
+
+	// countRecent cuenta las compras recientes que cumplen la condicion
+	countRecent := func(query string, args ...interface{}) (int64, error) {
+		var count int64
+		err := recent.Session(&gorm.Session{}).Where(query, args...).Count(&count).Error
+		return count, err
+	}
+
+	if s.Rules.MaxPerUser > 0 {
+		count, err := countRecent("user_id = ?", accrual.UserID)
+		if err != nil {
+			return "", nil, errors.New("error al evaluar la compra")
+		}
+		if count >= int64(s.Rules.MaxPerUser) {
+			apply("user_velocity", FraudActionHold)
+		}
+	}
+
+	if accrual.StaffID != "" {
+		if s.Rules.MaxPerStaff > 0 {
+			count, err := countRecent("staff_id = ?", accrual.StaffID)
+			if err != nil {
+				return "", nil, errors.New("error al evaluar la compra")
+			}
+			if count >= int64(s.Rules.MaxPerStaff) {
+				apply("staff_velocity", FraudActionFlag)
+			}
+		}
+
+		if s.Rules.MaxAccountsPerStaff > 0 {
+			var accounts int64
+			if err := recent.Session(&gorm.Session{}).
+				Where("staff_id = ? AND user_id <> ?", accrual.StaffID, accrual.UserID).
+				Distinct("user_id").
+				Count(&accounts).Error; err != nil {
+				return "", nil, errors.New("error al evaluar la compra")
+			}
+			if accounts >= int64(s.Rules.MaxAccountsPerStaff) {
+				apply("staff_many_accounts", FraudActionHold)
+			}
+		}
+	}
+
+	if accrual.StoreID != "" && s.Rules.MaxPerStore > 0 {
+		count, err := countRecent("store_id = ?", accrual.StoreID)
+		if err != nil {
+			return "", nil, errors.New("error al evaluar la compra")
+		}
+		if count >= int64(s.Rules.MaxPerStore) {
+			apply("store_velocity", FraudActionFlag)
+		}
+	}
+
+	return action, rules, nil
+}
+
+// CreateAlert registra una alerta para la compra sospechosa
+func (s *FraudService) CreateAlert(tx *gorm.DB, transaction *models.PointTransaction, action string, rules []string) error {
+	alert := models.FraudAlert{
+		ID:            uuid.NewString(),
+		TransactionID: transaction.ID,
+		UserID:        transaction.UserID,
+		Rules:         strings.Join(rules, ","),
+		Action:        action,
+		Status:        AlertOpen,
+		CreatedAt:     transaction.CreatedAt,
+	}
+	if err := tx.Create(&alert).Error; err != nil {
+		return errors.New("error al registrar la alerta")
+	}
+	return nil
+}
+
+// GetAlerts obtiene las alertas de la cola de revision filtradas por estado
+func (s *FraudService) GetAlerts(status string) ([]models.FraudAlert, error) {
+	var alerts []models.FraudAlert
+	query := s.DB.Order("created_at")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// ReviewAlert resuelve una alerta. Al aprobar una compra retenida se acreditan sus puntos y al
+// rechazar una compra marcada se retiran los puntos que ya se habian acreditado
+func (s *FraudService) ReviewAlert(alertID string, approve bool, note, reviewerID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+
+		var alert models.FraudAlert
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&alert, "id = ?", alertID).Error; err != nil {
+			return errors.New("alerta no encontrada")
+		}
+		if alert.Status != AlertOpen {
+			return errors.New("la alerta ya ha sido revisada")
+		}
+		if alert.UserID == reviewerID {
+			return errors.New("no puede revisar sus propias acumulaciones")
+		}
+
+		var transaction models.PointTransaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, "id = ?", alert.TransactionID).Error; err != nil {
+			return errors.New("movimiento de puntos no encontrado")
+		}
+
+		// Quien registro la compra tampoco puede aprobarla
+		if transaction.StaffID != "" && transaction.StaffID == reviewerID {
+			return errors.New("no puede revisar sus propias acumulaciones")
+		}
+
+		// Calculamos el ajuste de puntos que supone la decision
+		delta := 0
+		switch {
+		case approve && transaction.Status == TransactionHeld:
+			transaction.Status = TransactionCompleted
+			delta = transaction.Points
+		case !approve && transaction.Status == TransactionCompleted:
+			transaction.Status = TransactionRejected
+			delta = -transaction.Points
+		case !approve:
+			transaction.Status = TransactionRejected
+		}
+
+		if err := tx.Save(&transaction).Error; err != nil {
+			return errors.New("error al actualizar el movimiento de puntos")
+		}
+
+		if delta != 0 {
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", transaction.UserID).Error; err != nil {
+				return errors.New("usuario no encontrado")
+			}
+			user.Points += delta
+			user.Level = CalculateLevel(user.Points)
+			if err := tx.Save(&user).Error; err != nil {
+				return errors.New("error al actualizar los puntos del usuario")
+			}
+		}
+
+		now := time.Now()
+		alert.ReviewedAt = &now
+		alert.ReviewedBy = reviewerID
+		alert.Note = note
+		if approve {
+			alert.Status = AlertApproved
+		} else {
+			alert.Status = AlertRejected
+		}
+		if err := tx.Save(&alert).Error; err != nil {
+			return errors.New("error al guardar la alerta")
+		}
+		return nil
+	})
+}
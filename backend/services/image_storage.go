@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImageStorage guarda los ficheros de imagen y genera la URL estable con la que se sirven.
+// Permite sustituir el disco local por un almacenamiento compatible con S3 sin cambiar el servicio
+type ImageStorage interface {
+	Save(key string, data []byte, contentType string) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+// LocalImageStorage guarda las imagenes en un directorio local que se sirve bajo BaseURL
+type LocalImageStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+// Save escribe el fichero en el directorio, creando las carpetas necesarias
+func (s *LocalImageStorage) Save(key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Delete elimina el fichero si existe
+func (s *LocalImageStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// URL devuelve la direccion publica del fichero
+func (s *LocalImageStorage) URL(key string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key
+}
+
+// path convierte la clave en una ruta dentro del directorio, sin permitir salir de el
+func (s *LocalImageStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", errors.New("clave de imagen no valida")
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Tiempo que se reutiliza un ranking calculado antes de volver a consultar la base de datos
+	leaderboardCacheTTL = 5 * time.Minute
+	// Numero maximo de posiciones que se calculan y cachean por ranking
+	maxLeaderboardSize = 100
+	// Numero maximo de rankings en la cache, el mes y la tienda los elige el cliente
+	maxLeaderboardCacheEntries = 500
+)
+
+const monthFormat = "2006-01"
+
+type LeaderboardService struct {
+	DB *gorm.DB
+
+	mu    sync.Mutex
+	cache map[string]cachedLeaderboard
+}
+
+// Leaderboard es el ranking de un mes, opcionalmente filtrado por tienda
+type Leaderboard struct {
+	Month   string             `json:"month"`
+	StoreID string             `json:"store_id,omitempty"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardEntry es una posicion anonimizada del ranking
+type LeaderboardEntry struct {
+	Rank        int    `json:"rank"`
+	DisplayName string `json:"display_name"`
+	Points      int    `json:"points"`
+}
+
+type cachedLeaderboard struct {
+	entries   []LeaderboardEntry
+	expiresAt time.Time
+}
+
+// GetLeaderboard obtiene el ranking de clientes por puntos ganados en un mes, opcionalmente por tienda.
+// Si no se indica el mes se usa el mes actual del restaurante
+func (s *LeaderboardService) GetLeaderboard(month, storeID string, limit int) (*Leaderboard, error) {
+
+	if month == "" {
+		month = time.Now().In(businessLocation()).Format(monthFormat)
+	}
+
+	// Validar que el mes siga el formato `YYYY-MM`
+	start, err := time.ParseInLocation(monthFormat, month, businessLocation())
+	if err != nil {
+		return nil, errors.New("el formato de month debe ser YYYY-MM")
+	}
+	end := start.AddDate(0, 1, 0)
+
+	// Devolvemos el ranking cacheado si sigue vigente
+	key := month + "|" + storeID
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return &Leaderboard{Month: month, StoreID: storeID, Entries: truncateLeaderboard(cached.entries, limit)}, nil
+	}
+
+	// Sumamos los puntos ganados en el periodo por cada usuario que no se ha excluido del ranking
+	var rows []struct {
+		FirstName string
+		LastName  string
+		Points    int
+	}
+	query := s.DB.Table("point_transactions").
+		Select("users.first_name, users.last_name, SUM(point_transactions.points) AS points").
+		Joins("JOIN users ON users.id = point_transactions.user_id").
+		Where("point_transactions.created_at >= ? AND point_transactions.created_at < ?", start, end).
+		Where("point_transactions.status = ?", TransactionCompleted).
+		Where("users.leaderboard_opt_out = ?", false)
+	if storeID != "" {
+		query = query.Where("point_transactions.store_id = ?", storeID)
+	}
+	if err := query.Group("users.id, users.first_name, users.last_name").
+		Having("SUM(point_transactions.points) > 0").
+		Order("points DESC").
+		Limit(maxLeaderboardSize).
+		Scan(&rows).Error; err != nil {
+		return nil, errors.New("error al obtener el ranking")
+	}
+
+	entries := make([]LeaderboardEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = LeaderboardEntry{
+			Rank:        i + 1,
+			DisplayName: displayName(row.FirstName, row.LastName),
+			Points:      row.Points,
+		}
+	}
+
+	// Guardamos el ranking en la cache
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = make(map[string]cachedLeaderboard)
+	}
+	s.evictLeaderboards(time.Now())
+	s.cache[key] = cachedLeaderboard{entries: entries, expiresAt: time.Now().Add(leaderboardCacheTTL)}
+	s.mu.Unlock()
+
+	return &Leaderboard{Month: month, StoreID: storeID, Entries: truncateLeaderboard(entries, limit)}, nil
+}
+
+// evictLeaderboards quita de la cache los rankings caducados y, si sigue llena, el que caduca antes
+// para dejar sitio a uno nuevo. Se llama con el mutex bloqueado
+func (s *LeaderboardService) evictLeaderboards(now time.Time) {
+	for key, cached := range s.cache {
+		if !now.Before(cached.expiresAt) {
+			delete(s.cache, key)
+		}
+	}
+	if len(s.cache) < maxLeaderboardCacheEntries {
+		return
+	}
+	var oldestKey string
+	var oldest time.Time
+	for key, cached := range s.cache {
+		if oldestKey == "" || cached.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, cached.expiresAt
+		}
+	}
+	delete(s.cache, oldestKey)
+}
+
+// SetOptOut excluye o vuelve a incluir a un usuario en el ranking
+func (s *LeaderboardService) SetOptOut(userID string, optOut bool) error {
+	result := s.DB.Model(&models.User{}).Where("id = ?", userID).Update("leaderboard_opt_out", optOut)
+	if result.Error != nil {
+		return errors.New("error al actualizar el usuario")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("usuario no encontrado")
+	}
+
+	// Invalidamos la cache para que el cambio se refleje inmediatamente
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+
+	return nil
+}
+
+func truncateLeaderboard(entries []LeaderboardEntry, limit int) []LeaderboardEntry {
+	if limit > 0 && limit < len(entries) {
+		return entries[:limit]
+	}
+	return entries
+}
+
+// displayName anonimiza el nombre del usuario mostrando solo el nombre y la inicial del apellido
+func displayName(firstName, lastName string) string {
+	name := strings.TrimSpace(firstName)
+	if lastName = strings.TrimSpace(lastName); lastName != "" {
+		name += " " + strings.ToUpper(string([]rune(lastName)[:1])) + "."
+	}
+	return name
+}
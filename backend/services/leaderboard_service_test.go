@@ -0,0 +1,40 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvictLeaderboards(t *testing.T) {
+	now := utc(2026, time.October, 19, 12, 0)
+
+	t.Run("quita los rankings caducados", func(t *testing.T) {
+		s := LeaderboardService{cache: map[string]cachedLeaderboard{
+			"2026-09|":   {expiresAt: now.Add(-time.Minute)},
+			"2026-10|":   {expiresAt: now},
+			"2026-10|t1": {expiresAt: now.Add(time.Minute)},
+		}}
+		s.evictLeaderboards(now)
+		if len(s.cache) != 1 {
+			t.Fatalf("quedan %d rankings, se esperaba 1", len(s.cache))
+		}
+		if _, ok := s.cache["2026-10|t1"]; !ok {
+			t.Errorf("se ha quitado un ranking vigente")
+		}
+	})
+
+	t.Run("con la cache llena quita el que caduca antes", func(t *testing.T) {
+		s := LeaderboardService{cache: make(map[string]cachedLeaderboard)}
+		for i := 0; i < maxLeaderboardCacheEntries; i++ {
+			s.cache["2026-10|"+strconv.Itoa(i)] = cachedLeaderboard{expiresAt: now.Add(time.Duration(i+1) * time.Second)}
+		}
+		s.evictLeaderboards(now)
+		if len(s.cache) != maxLeaderboardCacheEntries-1 {
+			t.Fatalf("quedan %d rankings, se esperaban %d", len(s.cache), maxLeaderboardCacheEntries-1)
+		}
+		if _, ok := s.cache["2026-10|0"]; ok {
+			t.Errorf("no se ha quitado el ranking que caduca antes")
+		}
+	})
+}
@@ -4,12 +4,43 @@ import (
 	"errors"
 	"fidelity-client-app/models"
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PointsService struct {
-	DB *gorm.DB
+	DB               *gorm.DB
+	ChallengeService *ChallengeService
+	FraudService     *FraudService
+}
+
+// Tipos de movimiento de puntos
+const (
+	TransactionPurchase = "purchase"
+	TransactionBonus    = "bonus"
+	TransactionReversal = "reversal" // Retirada de los puntos extra de un consumo anulado
+)
+
+// Estados de un movimiento de puntos
+const (
+	TransactionCompleted = "completed"
+	TransactionHeld      = "held"
+	TransactionRejected  = "rejected"
+)
+
+// PointsAccrual contiene los datos de una compra sobre la que se acumulan puntos
+type PointsAccrual struct {
+	UserID         string
+	StoreID        string
+	StaffID        string
+	PurchaseAmount float64
+	Products       []string
+	Reference      string // Referencia externa de la compra (ej. reclamacion de ticket)
 }
 
 // CalculateLevel determina el nivel en funcion de los puntos totales del usuario
@@ -21,19 +52,110 @@ func CalculateLevel(points int) int {
 }
 
 // AccumulatePoints incrementa los puntos y actualiza el nivel del usuario
-func (s *PointsService) AccumulatePoints(userID string, purchaseAmount float64) (string, error) {
+func (s *PointsService) AccumulatePoints(accrual PointsAccrual) (string, error) {
+	var message string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// La tienda es la del empleado que registra la compra, para que no se pueda falsear
+		if accrual.StaffID != "" {
+			var staff models.User
+			if err := tx.First(&staff, "id = ?", accrual.StaffID).Error; err != nil {
+				return errors.New("empleado no encontrado")
+			}
+			accrual.StoreID = staff.StoreID
+		}
+
+		var err error
+		message, err = s.AccumulatePointsTx(tx, accrual)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return message, nil
+}
+
+// ValidPurchaseAmount indica si el importe de una compra es un numero finito mayor que 0
+func ValidPurchaseAmount(amount float64) bool {
+	return !math.IsNaN(amount) && !math.IsInf(amount, 0) && amount > 0
+}
+
+// AccumulatePointsTx acumula los puntos de una compra dentro de una transaccion ya abierta,
+// para que otros servicios puedan acreditar puntos de forma atomica con sus propios cambios
+func (s *PointsService) AccumulatePointsTx(tx *gorm.DB, accrual PointsAccrual) (string, error) {
+
+	// Un importe negativo restaria puntos y uno NaN esquivaria las reglas antifraude
+	if !ValidPurchaseAmount(accrual.PurchaseAmount) {
+		return "", errors.New("el monto de la compra tiene que ser mayor que 0")
+	}
 
 	// Calcular los puntos acumulados por la compra (20% de la compra)
-	pointsEarned := int(purchaseAmount * 1)
+	pointsEarned := int(accrual.PurchaseAmount * 1)
 
-	// Obtener el usuario desde la base de datos
+	// Obtener el usuario desde la base de datos bloqueando su fila hasta el final de la transaccion
 	var user models.User
-	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", accrual.UserID).Error; err != nil {
 		return "", errors.New("usuario no encontrado")
 	}
 
-	// Incrementar los puntos del usuario y determinar si hay un cambio de nivel
+	// Aplicar las reglas de deteccion de anomalias antes de registrar la compra
+	now := time.Now()
+	fraudAction := ""
+	var fraudRules []string
+	if s.FraudService != nil {
+		var err error
+		fraudAction, fraudRules, err = s.FraudService.EvaluateAccrual(tx, accrual, now)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Registrar la compra en el historial de movimientos de puntos
+	transaction := models.PointTransaction{
+		ID:             uuid.NewString(),
+		UserID:         user.ID,
+		Type:           TransactionPurchase,
+		Status:         TransactionCompleted,
+		PurchaseAmount: accrual.PurchaseAmount,
+		Points:         pointsEarned,
+		StoreID:        accrual.StoreID,
+		StaffID:        accrual.StaffID,
+		Products:       strings.Join(accrual.Products, ","),
+		Reference:      accrual.Reference,
+		CreatedAt:      now,
+	}
+	if fraudAction == FraudActionHold {
+		transaction.Status = TransactionHeld
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return "", errors.New("error al registrar la compra")
+	}
+
+	if fraudAction != "" {
+		if err := s.FraudService.CreateAlert(tx, &transaction, fraudAction, fraudRules); err != nil {
+			return "", err
+		}
+	}
+
+	// Las compras retenidas no acreditan puntos hasta que se aprueben
+	if transaction.Status == TransactionHeld {
+		return "La compra ha quedado retenida para revision. Los puntos se acreditaran cuando sea aprobada.", nil
+	}
+
+	// Incrementar los puntos del usuario
 	user.Points += pointsEarned
+
+	// Evaluar los retos activos con la nueva compra
+	var completedChallenges []models.Challenge
+	if s.ChallengeService != nil {
+		var err error
+		completedChallenges, err = s.ChallengeService.EvaluateChallenges(tx, &user, now)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Determinar si hay un cambio de nivel
 	newLevel := CalculateLevel(user.Points)
 	levelUp := false
 
@@ -43,15 +165,53 @@ func (s *PointsService) AccumulatePoints(userID string, purchaseAmount float64)
 	}
 
 	// Guardar los cambios del usuario en la base de datos
-	if err := s.DB.Save(&user).Error; err != nil {
+	if err := tx.Save(&user).Error; err != nil {
 		return "", errors.New("error al actualizar el nivel del usuario")
 	}
 
 	// Generar mensaje de confirmacion
 	message := fmt.Sprintf("Puntos acumulados: %d puntos añadidos.", pointsEarned)
+	for _, challenge := range completedChallenges {
+		message += fmt.Sprintf(" Reto completado: %s", challenge.Title)
+		if challenge.BonusPoints > 0 {
+			message += fmt.Sprintf(" (+%d puntos)", challenge.BonusPoints)
+		}
+		message += "."
+	}
 	if levelUp {
 		message += fmt.Sprintf(" Felicidades! Has alcanzado el nivel %d", newLevel)
 	}
 
 	return message, nil
 }
+
+// GetTransactions obtiene los movimientos de puntos del usuario, del mas reciente al mas antiguo, paginados por cursor
+func (s *PointsService) GetTransactions(userID, cursor string, limit int) ([]models.PointTransaction, string, error) {
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit = cursorLimit(limit)
+
+	query := s.DB.Where("user_id = ?", userID)
+	if before != nil {
+		createdAt, err := time.Parse(time.RFC3339Nano, before.Value)
+		if err != nil {
+			return nil, "", errors.New("el cursor no es valido")
+		}
+		query = query.Where("(created_at, id) < (?, ?)", createdAt, before.ID)
+	}
+
+	var transactions []models.PointTransaction
+	if err := query.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&transactions).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		last := transactions[limit-1]
+		next = encodeCursor(last.CreatedAt.Format(time.RFC3339Nano), last.ID)
+	}
+	return transactions, next, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Tipos de beneficio de una promocion
+const (
+	BenefitPercentage  = "percentage"
+	BenefitFixedAmount = "fixed_amount"
+	BenefitFreeProduct = "free_product"
+	BenefitBuyXGetY    = "buy_x_get_y"
+	BenefitPointsBonus = "points_bonus"
+)
+
+var currencyRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// BasketItem es una linea de la cesta de la compra
+type BasketItem struct {
+	Product   string  `json:"product"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+}
+
+// Basket es la cesta sobre la que se calcula el descuento de una promocion
+type Basket struct {
+	Currency string       `json:"currency"`
+	Items    []BasketItem `json:"items"`
+}
+
+// BasketDiscount es el resultado de aplicar una promocion a una cesta
+type BasketDiscount struct {
+	PromotionID string       `json:"promotion_id"`
+	BenefitType string       `json:"benefit_type"`
+	Subtotal    float64      `json:"subtotal"`
+	Discount    float64      `json:"discount"`
+	Total       float64      `json:"total"`
+	Currency    string       `json:"currency"`
+	FreeItems   []BasketItem `json:"free_items,omitempty"`
+	BonusPoints int          `json:"bonus_points,omitempty"`
+}
+
+// validateBenefit comprueba que la definicion del beneficio es coherente con su tipo
+func validateBenefit(promotion *models.Promotion) error {
+	promotion.Currency = strings.ToUpper(promotion.Currency)
+
+	switch promotion.BenefitType {
+	case "":
+		// Promocion informativa sin beneficio estructurado
+		return nil
+
+	case BenefitPercentage:
+		if promotion.DiscountPercent <= 0 || promotion.DiscountPercent > 100 {
+			return errors.New("el porcentaje de descuento debe estar entre 0 y 100")
+		}
+
+	case BenefitFixedAmount:
+		if promotion.DiscountAmount <= 0 {
+			return errors.New("el importe del descuento tiene que ser mayor que cero")
+		}
+		if !currencyRegex.MatchString(promotion.Currency) {
+			return errors.New("la moneda debe ser un codigo ISO 4217 de tres letras")
+		}
+
+	case BenefitFreeProduct:
+		if promotion.Product == "" {
+			return errors.New("el producto gratuito es obligatorio")
+		}
+
+	case BenefitBuyXGetY:
+		if promotion.Product == "" {
+			return errors.New("el producto de la promocion es obligatorio")
+		}
+		if promotion.BuyQuantity < 1 || promotion.GetQuantity < 1 {
+			return errors.New("las unidades a comprar y las unidades gratis tienen que ser mayores que cero")
+		}
+
+	case BenefitPointsBonus:
+		if promotion.BonusPoints < 1 {
+			return errors.New("los puntos extra tienen que ser mayores que cero")
+		}
+
+	default:
+		return errors.New("el tipo de beneficio no es valido")
+	}
+
+	return nil
+}
+
+// CalculateDiscount calcula el descuento que la promocion aplica sobre la cesta
+func CalculateDiscount(promotion *models.Promotion, basket Basket) (*BasketDiscount, error) {
+
+	if len(basket.Items) == 0 {
+		return nil, errors.New("la cesta esta vacia")
+	}
+
+	// Calculamos el subtotal de la cesta y las unidades de cada producto
+	var subtotal float64
+	quantities := make(map[string]int)
+	prices := make(map[string]float64)
+	for _, item := range basket.Items {
+		if item.Product == "" || item.Quantity < 1 || item.UnitPrice < 0 {
+			return nil, errors.New("las lineas de la cesta no son validas")
+		}
+		subtotal += float64(item.Quantity) * item.UnitPrice
+		quantities[item.Product] += item.Quantity
+		prices[item.Product] = item.UnitPrice
+	}
+
+	result := &BasketDiscount{
+		PromotionID: promotion.ID,
+		BenefitType: promotion.BenefitType,
+		Subtotal:    roundCents(subtotal),
+		Currency:    strings.ToUpper(basket.Currency),
+	}
+
+	switch promotion.BenefitType {
+	case BenefitPercentage:
+		result.Discount = subtotal * promotion.DiscountPercent / 100
+
+	case BenefitFixedAmount:
+		if result.Currency != promotion.Currency {
+			return nil, errors.New("la moneda de la cesta no coincide con la de la promocion")
+		}
+		result.Discount = math.Min(promotion.DiscountAmount, subtotal)
+
+	case BenefitFreeProduct:
+		// Si el producto esta en la cesta se descuenta una unidad, si no se entrega aparte
+		free := BasketItem{Product: promotion.Product, Quantity: 1}
+		if quantities[promotion.Product] > 0 {
+			free.UnitPrice = prices[promotion.Product]
+			result.Discount = free.UnitPrice
+		}
+		result.FreeItems = []BasketItem{free}
+
+	case BenefitBuyXGetY:
+		// Por cada grupo de X+Y unidades del producto, Y son gratis
+		group := promotion.BuyQuantity + promotion.GetQuantity
+		freeUnits := (quantities[promotion.Product] / group) * promotion.GetQuantity
+		if freeUnits > 0 {
+			free := BasketItem{Product: promotion.Product, Quantity: freeUnits, UnitPrice: prices[promotion.Product]}
+			result.Discount = float64(freeUnits) * free.UnitPrice
+			result.FreeItems = []BasketItem{free}
+		}
+
+	case BenefitPointsBonus:
+		result.BonusPoints = promotion.BonusPoints
+
+	default:
+		return nil, errors.New("la promocion no tiene un beneficio aplicable a la cesta")
+	}
+
+	result.Discount = roundCents(result.Discount)
+	result.Total = roundCents(subtotal - result.Discount)
+	return result, nil
+}
+
+// roundCents redondea un importe a centimos
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
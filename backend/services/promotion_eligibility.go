@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Motivos por los que un usuario no puede consumir una promocion
+const (
+	ReasonNotActive       = "not_active"       // Fuera del periodo de vigencia
+	ReasonOutOfSchedule   = "out_of_schedule"  // Fuera de sus franjas horarias
+	ReasonNoStampReward   = "no_stamp_reward"  // Sin recompensa de sellos pendiente
+	ReasonLevelTooLow     = "level_too_low"    // Nivel del usuario insuficiente
+	ReasonSegmentMismatch = "segment_mismatch" // El usuario no pertenece a sus segmentos
+	ReasonLimitReached    = "limit_reached"    // Limite de usos por usuario alcanzado
+	ReasonSoldOut         = "sold_out"         // Sin usos totales disponibles
+	ReasonOtherVariant    = "other_variant"    // Al usuario le corresponde otra variante del experimento
+)
+
+// PromotionEligibility es una promocion junto con si el usuario puede consumirla y por que no
+type PromotionEligibility struct {
+	models.Promotion
+	Eligible bool     `json:"eligible"`
+	Reasons  []string `json:"reasons"`
+	Variant  string   `json:"variant,omitempty"` // Variante del experimento A/B al que pertenece la promocion
+}
+
+// eligibilityRules contiene los datos de un usuario necesarios para evaluar si puede consumir
+// un conjunto de promociones. Lo usan tanto el listado como el consumo para aplicar las mismas reglas
+type eligibilityRules struct {
+	user           *models.User
+	now            time.Time
+	audience       *audienceMatcher
+	usages         []models.PromotionUsage
+	stampRewards   map[string]bool              // Promociones que son recompensa de una tarjeta de sellos
+	pendingRewards map[string]int               // Recompensas de sellos pendientes del usuario por promocion
+	variants       map[string]variantAssignment // Promociones que son variantes de un experimento en marcha
+}
+
+// loadEligibilityRules carga los usos y recompensas del usuario para las promociones indicadas
+func loadEligibilityRules(db *gorm.DB, user *models.User, promotionIDs []string, now time.Time) (*eligibilityRules, error) {
+	rules := eligibilityRules{
+		user:           user,
+		now:            now,
+		audience:       newAudienceMatcher(db, user, now),
+		stampRewards:   make(map[string]bool),
+		pendingRewards: make(map[string]int),
+	}
+	if len(promotionIDs) == 0 {
+		rules.variants = make(map[string]variantAssignment)
+		return &rules, nil
+	}
+
+	var err error
+	if rules.variants, err = loadVariantAssignments(db, user.ID, promotionIDs); err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("user_id = ? AND promotion_id IN ? AND voided_at IS NULL", user.ID, promotionIDs).Find(&rules.usages).Error; err != nil {
+		return nil, err
+	}
+
+	var rewardPromotions []string
+	if err := db.Model(&models.StampCard{}).Where("reward_promotion_id IN ?", promotionIDs).
+		Pluck("reward_promotion_id", &rewardPromotions).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range rewardPromotions {
+		rules.stampRewards[id] = true
+	}
+
+	if len(rewardPromotions) > 0 {
+		var rewards []models.StampReward
+		if err := db.Where("user_id = ? AND promotion_id IN ? AND redeemed_at IS NULL", user.ID, rewardPromotions).
+			Find(&rewards).Error; err != nil {
+			return nil, err
+		}
+		for _, reward := range rewards {
+			rules.pendingRewards[reward.PromotionID]++
+		}
+	}
+
+	return &rules, nil
+}
+
+// evaluate devuelve los motivos por los que el usuario no puede consumir la promocion (vacio = puede)
+func (r *eligibilityRules) evaluate(promotion *models.Promotion) ([]string, error) {
+	reasons := []string{}
+
+	// Las variantes de un experimento que no corresponden al usuario no se le muestran ni las puede consumir
+	if variant, ok := r.variants[promotion.ID]; ok && !variant.assigned {
+		return append(reasons, ReasonOtherVariant), nil
+	}
+
+	if !isPromotionActiveOn(promotion, businessToday(r.now)) {
+		reasons = append(reasons, ReasonNotActive)
+	}
+	if !inSchedule(promotion.Schedules, r.now) {
+		reasons = append(reasons, ReasonOutOfSchedule)
+	}
+
+	// Las promociones de recompensa de tarjetas de sellos solo requieren una recompensa pendiente
+	if r.stampRewards[promotion.ID] {
+		if r.pendingRewards[promotion.ID] == 0 {
+			reasons = append(reasons, ReasonNoStampReward)
+		}
+		return reasons, nil
+	}
+
+	if r.user.Level < promotion.LevelRequired {
+		reasons = append(reasons, ReasonLevelTooLow)
+	}
+
+	matched, err := r.audience.matches(promotion)
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		reasons = append(reasons, ReasonSegmentMismatch)
+	}
+
+	if r.usedInPeriod(promotion) >= promotion.PerUserLimit {
+		reasons = append(reasons, ReasonLimitReached)
+	}
+	if promotion.MaxRedemptions > 0 && promotion.RedemptionCount >= promotion.MaxRedemptions {
+		reasons = append(reasons, ReasonSoldOut)
+	}
+	return reasons, nil
+}
+
+// usedInPeriod cuenta los usos del usuario en el periodo actual del limite por usuario
+func (r *eligibilityRules) usedInPeriod(promotion *models.Promotion) int {
+	since := usagePeriodStart(promotion.PerUserPeriod, r.now)
+	used := 0
+	for _, usage := range r.usages {
+		if usage.PromotionID == promotion.ID && !usage.ConsumedAt.Before(since) {
+			used++
+		}
+	}
+	return used
+}
+
+// eligibilityError traduce el motivo de no elegibilidad al error que devuelve el consumo
+func eligibilityError(promotion *models.Promotion, reason string) error {
+	switch reason {
+	case ReasonNotActive:
+		return errors.New("la promoción no está activa en este momento")
+	case ReasonOutOfSchedule:
+		return errors.New("la promoción no está disponible en este horario")
+	case ReasonNoStampReward:
+		return errors.New("el usuario no tiene recompensas de sellos pendientes para esta promoción")
+	case ReasonLevelTooLow:
+		return errors.New("el usuario no tiene el nivel necesario para consumir esta promoción")
+	case ReasonSegmentMismatch:
+		return errors.New("la promoción no está dirigida a este usuario")
+	case ReasonLimitReached:
+		if promotion.PerUserLimit == 1 && promotion.PerUserPeriod == "" {
+			return errors.New("esta promoción ya ha sido consumida por el usuario")
+		}
+		return errors.New("el usuario ha alcanzado el limite de usos de esta promoción")
+	case ReasonSoldOut:
+		return errors.New("la promoción se ha agotado")
+	case ReasonOtherVariant:
+		return errors.New("al usuario le corresponde otra variante de esta promoción")
+	}
+	return errors.New("el usuario no puede consumir esta promoción")
+}
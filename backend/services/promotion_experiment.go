@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fidelity-client-app/models"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// variantAssignment indica si una promocion que forma parte de un experimento en marcha es la
+// variante asignada al usuario
+type variantAssignment struct {
+	experimentID string
+	variantID    string
+	variantName  string
+	assigned     bool
+}
+
+// assignVariant elige de forma determinista la variante del usuario a partir del hash de su id,
+// de modo que siempre ve la misma version mientras no cambien las variantes del experimento
+func assignVariant(experimentID, userID string, variants []models.ExperimentVariant) *models.ExperimentVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	ordered := append([]models.ExperimentVariant(nil), variants...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Name < ordered[j].Name })
+
+	total := 0
+	for _, variant := range ordered {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return &ordered[0]
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(experimentID + ":" + userID))
+	bucket := int(hash.Sum32() % uint32(total))
+	for i := range ordered {
+		if bucket < ordered[i].Weight {
+			return &ordered[i]
+		}
+		bucket -= ordered[i].Weight
+	}
+	return &ordered[len(ordered)-1]
+}
+
+// loadVariantAssignments devuelve, para las promociones que son variantes de un experimento en
+// marcha, si corresponden a la variante asignada al usuario
+func loadVariantAssignments(db *gorm.DB, userID string, promotionIDs []string) (map[string]variantAssignment, error) {
+	assignments := make(map[string]variantAssignment)
+	if len(promotionIDs) == 0 {
+		return assignments, nil
+	}
+
+	var experimentIDs []string
+	if err := db.Model(&models.ExperimentVariant{}).
+		Joins("JOIN experiments ON experiments.id = experiment_variants.experiment_id").
+		Where("experiment_variants.promotion_id IN ? AND experiments.status = ?", promotionIDs, ExperimentRunning).
+		Distinct().Pluck("experiment_variants.experiment_id", &experimentIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(experimentIDs) == 0 {
+		return assignments, nil
+	}
+
+	var variants []models.ExperimentVariant
+	if err := db.Where("experiment_id IN ?", experimentIDs).Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	byExperiment := make(map[string][]models.ExperimentVariant)
+	for _, variant := range variants {
+		byExperiment[variant.ExperimentID] = append(byExperiment[variant.ExperimentID], variant)
+	}
+
+	for experimentID, variants := range byExperiment {
+		assigned := assignVariant(experimentID, userID, variants)
+		for _, variant := range variants {
+			assignments[variant.PromotionID] = variantAssignment{
+				experimentID: experimentID,
+				variantID:    variant.ID,
+				variantName:  variant.Name,
+				assigned:     variant.ID == assigned.ID,
+			}
+		}
+	}
+	return assignments, nil
+}
+
+// recordExposures guarda la primera vez que se muestra al usuario la variante de cada experimento
+func recordExposures(db *gorm.DB, userID string, assignments []variantAssignment, now time.Time) error {
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	exposures := make([]models.ExperimentExposure, 0, len(assignments))
+	for _, assignment := range assignments {
+		exposures = append(exposures, models.ExperimentExposure{
+			ExperimentID: assignment.experimentID,
+			UserID:       userID,
+			VariantID:    assignment.variantID,
+			ExposedAt:    now,
+		})
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&exposures).Error
+}
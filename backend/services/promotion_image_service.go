@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fidelity-client-app/models"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromotionImageService struct {
+	DB      *gorm.DB
+	Storage ImageStorage
+}
+
+// Tamaños generados para cada imagen subida. Las imagenes no se amplian si son mas pequeñas
+var promotionImageVariants = []struct {
+	Name  string
+	Width int
+}{
+	{"hero", 1200},
+	{"thumbnail_large", 600},
+	{"thumbnail_medium", 300},
+	{"thumbnail_small", 150},
+}
+
+// Limite de dimensiones de la imagen original para no descomprimir imagenes desproporcionadas
+const maxPromotionImagePixels = 50_000_000
+
+// UploadImage valida la imagen de una promocion, genera sus versiones redimensionadas y sustituye las anteriores
+func (s *PromotionImageService) UploadImage(promotionID string, data []byte) ([]models.PromotionImage, error) {
+
+	// Comprobamos que la promocion existe
+	var promotion models.Promotion
+	if err := s.DB.First(&promotion, "id = ?", promotionID).Error; err != nil {
+		return nil, errors.New("promotion not found")
+	}
+
+	// Solo aceptamos JPEG y PNG segun el contenido real del fichero
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, errors.New("la imagen debe ser JPEG o PNG")
+	}
+
+	size, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("la imagen no es valida")
+	}
+	if size.Width*size.Height > maxPromotionImagePixels {
+		return nil, errors.New("las dimensiones de la imagen son demasiado grandes")
+	}
+
+	original, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("la imagen no es valida")
+	}
+
+	// Generamos y guardamos cada version de la imagen
+	now := time.Now()
+	imageID := uuid.NewString()
+	var images []models.PromotionImage
+	for _, variant := range promotionImageVariants {
+		resized := resizeToWidth(original, variant.Width)
+
+		encoded, extension, err := encodeImage(resized, contentType)
+		if err != nil {
+			s.deleteFiles(images)
+			return nil, errors.New("error al procesar la imagen")
+		}
+
+		key := "promotions/" + promotionID + "/" + imageID + "-" + variant.Name + extension
+		if err := s.Storage.Save(key, encoded, contentType); err != nil {
+			s.deleteFiles(images)
+			return nil, errors.New("error al guardar la imagen")
+		}
+
+		images = append(images, models.PromotionImage{
+			ID:          uuid.NewString(),
+			PromotionID: promotionID,
+			Variant:     variant.Name,
+			StorageKey:  key,
+			URL:         s.Storage.URL(key),
+			ContentType: contentType,
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			CreatedAt:   now,
+		})
+	}
+
+	// Sustituimos las imagenes anteriores de la promocion
+	var previous []models.PromotionImage
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Bloqueamos la promocion para que dos subidas a la vez no dejen imagenes de ambas
+		var promotion models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, "id = ?", promotionID).Error; err != nil {
+			return errors.New("promotion not found")
+		}
+		if err := tx.Where("promotion_id = ?", promotionID).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("promotion_id = ?", promotionID).Delete(&models.PromotionImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&images).Error
+	})
+	if err != nil {
+		s.deleteFiles(images)
+		if err.Error() == "promotion not found" {
+			return nil, err
+		}
+		return nil, errors.New("error al guardar la imagen")
+	}
+
+	s.deleteFiles(previous)
+	return images, nil
+}
+
+// DeleteImages elimina las imagenes de una promocion
+func (s *PromotionImageService) DeleteImages(promotionID string) error {
+	var images []models.PromotionImage
+	if err := s.DB.Where("promotion_id = ?", promotionID).Find(&images).Error; err != nil {
+		return errors.New("error al obtener las imagenes")
+	}
+	if len(images) == 0 {
+		return errors.New("la promocion no tiene imagenes")
+	}
+	if err := s.DB.Where("promotion_id = ?", promotionID).Delete(&models.PromotionImage{}).Error; err != nil {
+		return errors.New("error al eliminar las imagenes")
+	}
+	s.deleteFiles(images)
+	return nil
+}
+
+// deleteFiles borra del almacenamiento los ficheros de las imagenes. Los errores se ignoran porque
+// un fichero huerfano no afecta a la promocion
+func (s *PromotionImageService) deleteFiles(images []models.PromotionImage) {
+	for _, img := range images {
+		s.Storage.Delete(img.StorageKey)
+	}
+}
+
+// resizeToWidth redimensiona la imagen al ancho indicado manteniendo la proporcion
+func resizeToWidth(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	if bounds.Dx() <= width {
+		return src
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+// encodeImage codifica la imagen en el mismo formato que el original
+func encodeImage(img image.Image, contentType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), ".png", nil
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), ".jpg", nil
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fidelity-client-app/models"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formatos de importacion y exportacion de promociones
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Columnas del CSV de promociones. Las franjas horarias se escriben como `1,2,3@12:00-16:00`
+// separadas por `;` y los segmentos como ids separados por `;`
+var promotionCSVColumns = []string{
+	"title", "description", "level_required", "start_date", "end_date",
+	"benefit_type", "discount_percent", "discount_amount", "currency", "product",
+	"buy_quantity", "get_quantity", "bonus_points",
+	"exclusivity_group", "exclusive", "stacking_priority",
+	"max_redemptions", "per_user_limit", "per_user_period",
+	"schedules", "segments",
+}
+
+// ImportRowError es el error de validacion de una fila del fichero importado
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResult resume el resultado de una importacion de promociones
+type ImportResult struct {
+	DryRun     bool               `json:"dry_run"`
+	Total      int                `json:"total"`
+	Created    int                `json:"created"`
+	Errors     []ImportRowError   `json:"errors"`
+	Promotions []models.Promotion `json:"promotions,omitempty"`
+}
+
+// importRow es una promocion leida del fichero junto con su linea o el error al interpretarla
+type importRow struct {
+	Line      int
+	Promotion models.Promotion
+	Err       error
+}
+
+// ImportPromotions crea en bloque las promociones de un fichero CSV o JSON con las mismas reglas que
+// CreatePromotion. Si alguna fila tiene errores no se crea ninguna, y en modo dryRun solo se validan
+func (s *PromotionService) ImportPromotions(data []byte, format string, dryRun bool, authorID string) (*ImportResult, error) {
+	var rows []importRow
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = parsePromotionsCSV(data)
+	case FormatJSON:
+		rows, err = parsePromotionsJSON(data)
+	default:
+		return nil, errors.New("el formato debe ser csv o json")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("el fichero no contiene promociones")
+	}
+
+	result := ImportResult{DryRun: dryRun, Total: len(rows), Errors: []ImportRowError{}}
+
+	// Guardamos cada fila en un punto de guardado para informar de los errores de todas las filas
+	// y confirmamos solo si ninguna ha fallado y no es una simulacion
+	tx := s.DB.Begin()
+	if tx.Error != nil {
+		return nil, errors.New("error al importar las promociones")
+	}
+	defer tx.Rollback()
+
+	promotions := make([]models.Promotion, 0, len(rows))
+	for _, row := range rows {
+		if row.Err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Line: row.Line, Error: row.Err.Error()})
+			continue
+		}
+
+		promotion := row.Promotion
+		if err := validatePromotion(&promotion); err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Line: row.Line, Error: err.Error()})
+			continue
+		}
+
+		// Si no se puede deshacer la fila la transaccion queda abortada y fallarian todas las demas
+		if err := tx.SavePoint("import_row").Error; err != nil {
+			return nil, errors.New("error al importar las promociones")
+		}
+		if err := insertPromotion(tx, &promotion, authorID); err != nil {
+			if err := tx.RollbackTo("import_row").Error; err != nil {
+				return nil, errors.New("error al importar las promociones")
+			}
+			result.Errors = append(result.Errors, ImportRowError{Line: row.Line, Error: err.Error()})
+			continue
+		}
+		promotions = append(promotions, promotion)
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		return &result, nil
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("error al importar las promociones")
+	}
+
+	result.Created = len(promotions)
+	result.Promotions = promotions
+	return &result, nil
+}
+
+// ExportPromotions escribe todas las promociones en formato CSV o JSON, compatible con la importacion
+func (s *PromotionService) ExportPromotions(format string, w io.Writer) error {
+	if format != FormatCSV && format != FormatJSON {
+		return errors.New("el formato debe ser csv o json")
+	}
+
+	var promotions []models.Promotion
+	if err := s.DB.Preload("Schedules").Preload("Segments").Order("start_date, id").Find(&promotions).Error; err != nil {
+		return errors.New("error al obtener las promociones")
+	}
+
+	if format == FormatJSON {
+		return json.NewEncoder(w).Encode(promotions)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(promotionCSVColumns); err != nil {
+		return err
+	}
+	for _, promotion := range promotions {
+		if err := writer.Write(promotionCSVRecord(&promotion)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// parsePromotionsJSON lee una lista JSON de promociones guardando la linea en la que empieza cada una
+func parsePromotionsJSON(data []byte) ([]importRow, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("el JSON debe ser una lista de promociones")
+	}
+
+	var rows []importRow
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, errors.New("el JSON no es valido")
+		}
+
+		// La linea es la del ultimo caracter del elemento menos los saltos de linea que contiene
+		end := int(decoder.InputOffset())
+		line := 1 + bytes.Count(data[:end], []byte("\n")) - bytes.Count(raw, []byte("\n"))
+
+		row := importRow{Line: line}
+		if err := json.Unmarshal(raw, &row.Promotion); err != nil {
+			if errors.Is(err, models.ErrInvalidDate) {
+				row.Err = err
+			} else {
+				row.Err = errors.New("la promocion contiene valores no validos")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parsePromotionsCSV lee un CSV de promociones cuya cabecera indica el orden de las columnas
+func parsePromotionsCSV(data []byte) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("el CSV debe tener una cabecera")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if !contains(promotionCSVColumns, name) {
+			return nil, errors.New("la columna " + name + " no es valida")
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("el CSV debe tener la columna title")
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("el CSV no es valido: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		promotion, err := promotionFromCSV(get)
+		rows = append(rows, importRow{Line: line, Promotion: promotion, Err: err})
+	}
+	return rows, nil
+}
+
+// promotionFromCSV construye la promocion a partir de los valores de las columnas de una fila
+func promotionFromCSV(get func(string) string) (models.Promotion, error) {
+	promotion := models.Promotion{
+		Title:            get("title"),
+		Description:      get("description"),
+		BenefitType:      get("benefit_type"),
+		Currency:         get("currency"),
+		Product:          get("product"),
+		PerUserPeriod:    get("per_user_period"),
+		ExclusivityGroup: get("exclusivity_group"),
+	}
+
+	if value := get("exclusive"); value != "" {
+		exclusive, err := strconv.ParseBool(value)
+		if err != nil {
+			return promotion, errors.New("la columna exclusive debe ser true o false")
+		}
+		promotion.Exclusive = exclusive
+	}
+
+	// Columnas numericas (vacio = 0)
+	ints := map[string]*int{
+		"level_required":    &promotion.LevelRequired,
+		"buy_quantity":      &promotion.BuyQuantity,
+		"get_quantity":      &promotion.GetQuantity,
+		"bonus_points":      &promotion.BonusPoints,
+		"stacking_priority": &promotion.StackingPriority,
+		"max_redemptions":   &promotion.MaxRedemptions,
+		"per_user_limit":    &promotion.PerUserLimit,
+	}
+	for name, target := range ints {
+		if value := get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return promotion, errors.New("la columna " + name + " debe ser un numero entero")
+			}
+			*target = n
+		}
+	}
+	floats := map[string]*float64{
+		"discount_percent": &promotion.DiscountPercent,
+		"discount_amount":  &promotion.DiscountAmount,
+	}
+	for name, target := range floats {
+		if value := get(name); value != "" {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return promotion, errors.New("la columna " + name + " debe ser un numero")
+			}
+			*target = n
+		}
+	}
+
+	// Fechas
+	if value := get("start_date"); value != "" {
+		startDate, err := models.ParseDate(value)
+		if err != nil {
+			return promotion, errors.New("el formato de start_date debe ser YYYY-MM-DD")
+		}
+		promotion.StartDate = startDate
+	}
+	if value := get("end_date"); value != "" {
+		endDate, err := models.ParseDate(value)
+		if err != nil {
+			return promotion, errors.New("el formato de end_date debe ser YYYY-MM-DD")
+		}
+		promotion.EndDate = &endDate
+	}
+
+	// Franjas horarias: `dias@inicio-fin` separadas por `;`
+	for _, value := range splitNonEmpty(get("schedules"), ";") {
+		days, hours, ok := strings.Cut(value, "@")
+		start, end, ok2 := strings.Cut(hours, "-")
+		if !ok || !ok2 {
+			return promotion, errors.New("las franjas horarias deben tener el formato 1,2,3@HH:MM-HH:MM")
+		}
+		promotion.Schedules = append(promotion.Schedules, models.PromotionSchedule{
+			Weekdays:  strings.TrimSpace(days),
+			StartTime: strings.TrimSpace(start),
+			EndTime:   strings.TrimSpace(end),
+		})
+	}
+
+	// Segmentos: ids separados por `;`
+	for _, id := range splitNonEmpty(get("segments"), ";") {
+		promotion.Segments = append(promotion.Segments, models.PromotionSegment{SegmentID: id})
+	}
+
+	return promotion, nil
+}
+
+// promotionCSVRecord convierte la promocion en una fila con las columnas del CSV
+func promotionCSVRecord(promotion *models.Promotion) []string {
+	endDate := ""
+	if promotion.EndDate != nil {
+		endDate = promotion.EndDate.String()
+	}
+
+	schedules := make([]string, 0, len(promotion.Schedules))
+	for _, schedule := range promotion.Schedules {
+		schedules = append(schedules, schedule.Weekdays+"@"+schedule.StartTime+"-"+schedule.EndTime)
+	}
+	segments := make([]string, 0, len(promotion.Segments))
+	for _, segment := range promotion.Segments {
+		segments = append(segments, segment.SegmentID)
+	}
+
+	return []string{
+		promotion.Title,
+		promotion.Description,
+		strconv.Itoa(promotion.LevelRequired),
+		promotion.StartDate.String(),
+		endDate,
+		promotion.BenefitType,
+		strconv.FormatFloat(promotion.DiscountPercent, 'f', -1, 64),
+		strconv.FormatFloat(promotion.DiscountAmount, 'f', -1, 64),
+		promotion.Currency,
+		promotion.Product,
+		strconv.Itoa(promotion.BuyQuantity),
+		strconv.Itoa(promotion.GetQuantity),
+		strconv.Itoa(promotion.BonusPoints),
+		promotion.ExclusivityGroup,
+		strconv.FormatBool(promotion.Exclusive),
+		strconv.Itoa(promotion.StackingPriority),
+		strconv.Itoa(promotion.MaxRedemptions),
+		strconv.Itoa(promotion.PerUserLimit),
+		promotion.PerUserPeriod,
+		strings.Join(schedules, ";"),
+		strings.Join(segments, ";"),
+	}
+}
+
+// splitNonEmpty separa la cadena y descarta los elementos vacios
+func splitNonEmpty(value, separator string) []string {
+	var items []string
+	for _, item := range strings.Split(value, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
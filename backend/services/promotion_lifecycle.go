@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Estados del ciclo de vida de una promocion
+const (
+	PromotionDraft     = "draft"
+	PromotionInReview  = "in_review"
+	PromotionScheduled = "scheduled" // Aprobada con fecha de inicio futura
+	PromotionPublished = "published"
+	PromotionPaused    = "paused"
+	PromotionArchived  = "archived"
+)
+
+// Acciones que cambian el estado de una promocion
+const (
+	ActionSubmit  = "submit"  // draft -> in_review
+	ActionReject  = "reject"  // in_review -> draft
+	ActionApprove = "approve" // in_review -> scheduled | published
+	ActionPause   = "pause"   // scheduled | published -> paused
+	ActionResume  = "resume"  // paused -> scheduled | published
+	ActionArchive = "archive" // cualquier estado -> archived
+)
+
+// Estados en los que la promocion es visible para los clientes dentro de su periodo de vigencia
+var livePromotionStatuses = []string{PromotionScheduled, PromotionPublished}
+
+// promotionTransition indica desde que estados se puede aplicar una accion y que roles pueden hacerlo
+type promotionTransition struct {
+	from  []string
+	roles []string
+}
+
+var promotionTransitions = map[string]promotionTransition{
+	ActionSubmit: {
+		from:  []string{PromotionDraft},
+		roles: []string{models.RoleMarketing, models.RoleMarketingManager, models.RoleAdmin},
+	},
+	ActionReject: {
+		from:  []string{PromotionInReview},
+		roles: []string{models.RoleMarketingManager, models.RoleAdmin},
+	},
+	ActionApprove: {
+		from:  []string{PromotionInReview},
+		roles: []string{models.RoleMarketingManager, models.RoleAdmin},
+	},
+	ActionPause: {
+		from:  []string{PromotionScheduled, PromotionPublished},
+		roles: []string{models.RoleMarketingManager, models.RoleAdmin},
+	},
+	ActionResume: {
+		from:  []string{PromotionPaused},
+		roles: []string{models.RoleMarketingManager, models.RoleAdmin},
+	},
+	ActionArchive: {
+		from:  []string{PromotionDraft, PromotionInReview, PromotionScheduled, PromotionPublished, PromotionPaused},
+		roles: []string{models.RoleMarketingManager, models.RoleAdmin},
+	},
+}
+
+// TransitionPromotion aplica una accion del ciclo de vida a la promocion si el rol tiene permiso
+func (s *PromotionService) TransitionPromotion(id, action, userID, role string) (*models.Promotion, error) {
+	transition, ok := promotionTransitions[action]
+	if !ok {
+		return nil, errors.New("la accion debe ser submit, reject, approve, pause, resume o archive")
+	}
+	if !contains(transition.roles, role) {
+		return nil, errors.New("no tiene permisos para realizar esta accion")
+	}
+
+	var promotion models.Promotion
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, "id = ?", id).Error; err != nil {
+			return errors.New("promotion not found")
+		}
+		if !contains(transition.from, promotion.Status) {
+			return errors.New("la promocion en estado " + promotion.Status + " no admite la accion " + action)
+		}
+
+		now := time.Now()
+		status := PromotionDraft
+		switch action {
+		case ActionSubmit:
+			status = PromotionInReview
+		case ActionPause:
+			status = PromotionPaused
+		case ActionArchive:
+			status = PromotionArchived
+		case ActionApprove, ActionResume:
+			// Si todavia no ha empezado queda programada hasta su fecha de inicio
+			status = PromotionPublished
+			if promotion.StartDate.After(businessToday(now).Time) {
+				status = PromotionScheduled
+			}
+		}
+
+		promotion.Status = status
+		promotion.StatusChangedAt = &now
+		promotion.StatusChangedBy = userID
+		if err := tx.Model(&promotion).Select("status", "status_changed_at", "status_changed_by").Updates(&promotion).Error; err != nil {
+			return errors.New("error al cambiar el estado de la promocion")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// PublishScheduledPromotions pasa a publicadas las promociones programadas cuya fecha de inicio ya ha llegado
+func (s *PromotionService) PublishScheduledPromotions(now time.Time) error {
+	return s.DB.Model(&models.Promotion{}).
+		Where("status = ? AND start_date <= ?", PromotionScheduled, businessToday(now)).
+		Updates(map[string]interface{}{"status": PromotionPublished, "status_changed_at": now}).Error
+}
+
+// activeOnScope filtra las promociones visibles para los clientes y vigentes en la fecha indicada
+func activeOnScope(today models.Date) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("status IN ? AND start_date <= ? AND (end_date IS NULL OR end_date >= ?)", livePromotionStatuses, today, today)
+	}
+}
+
+// contains indica si el valor esta en la lista
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fidelity-client-app/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PatchPromotion aplica un JSON merge patch (RFC 7396) a la promocion. Los campos enviados con
+// null se vacian y los que no se envian se mantienen. Solo se aplica si expectedVersion coincide
+// con la version actual, para no sobrescribir los cambios de otro usuario
+func (s *PromotionService) PatchPromotion(id string, patch []byte, expectedVersion int, authorID string) (*models.Promotion, error) {
+	var changes map[string]interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, errors.New("el parche debe ser un objeto JSON")
+	}
+
+	var patched models.Promotion
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		promotion, err := lockPromotionForEdit(tx, id, expectedVersion)
+		if err != nil {
+			return err
+		}
+
+		// Aplicamos el parche sobre la representacion JSON actual de la promocion
+		current, err := json.Marshal(promotion)
+		if err != nil {
+			return errors.New("error al aplicar el parche")
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(current, &fields); err != nil {
+			return errors.New("error al aplicar el parche")
+		}
+		merged, err := json.Marshal(mergePatch(fields, changes))
+		if err != nil {
+			return errors.New("error al aplicar el parche")
+		}
+		if err := json.Unmarshal(merged, &patched); err != nil {
+			if errors.Is(err, models.ErrInvalidDate) {
+				return err
+			}
+			return errors.New("el parche contiene valores no validos")
+		}
+
+		// Los campos gestionados por el sistema conservan su valor
+		patched.ID = promotion.ID
+		patched.Status = promotion.Status
+		patched.StatusChangedAt = promotion.StatusChangedAt
+		patched.StatusChangedBy = promotion.StatusChangedBy
+		patched.Version = promotion.Version
+		patched.RedemptionCount = promotion.RedemptionCount
+
+		if err := validatePromotion(&patched); err != nil {
+			return err
+		}
+
+		// Guardamos todas las columnas para que los valores vacios tambien se escriban
+		if err := tx.Model(promotion).Select("*").Omit(append([]string{clause.Associations}, protectedPromotionColumns...)...).
+			Updates(&patched).Error; err != nil {
+			return errors.New("error updating promotion")
+		}
+
+		// Las franjas y los segmentos se sustituyen completos solo si el parche los incluye
+		if _, ok := changes["schedules"]; ok {
+			if err := replaceSchedules(tx, promotion.ID, patched.Schedules); err != nil {
+				return err
+			}
+		}
+		if _, ok := changes["segments"]; ok {
+			if err := replaceSegments(tx, promotion.ID, patched.Segments); err != nil {
+				return err
+			}
+		}
+
+		revision, err := recordRevision(tx, promotion.ID, authorID, time.Now())
+		if err != nil {
+			return err
+		}
+		patched.Version = revision.Version
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
+
+// mergePatch aplica un parche JSON merge patch sobre el objeto: null elimina el campo, los objetos
+// se combinan de forma recursiva y el resto de valores (incluidas las listas) se sustituyen
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	if target == nil {
+		target = make(map[string]interface{})
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if patchObject, ok := value.(map[string]interface{}); ok {
+			targetObject, _ := target[key].(map[string]interface{})
+			target[key] = mergePatch(targetObject, patchObject)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fidelity-client-app/models"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Campos de la promocion que no forman parte de sus condiciones y no se guardan en las revisiones
+var revisionIgnoredFields = []string{
+	"redemption_count", "remaining", "status", "status_changed_at", "status_changed_by", "version", "deleted_at",
+}
+
+// RevisionChange es el valor de un campo antes y despues de una revision
+type RevisionChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// PromotionRevisionEntry es una revision de la promocion con sus condiciones y los cambios respecto a la anterior
+type PromotionRevisionEntry struct {
+	models.PromotionRevision
+	Snapshot json.RawMessage           `json:"snapshot"`
+	Changes  map[string]RevisionChange `json:"changes"`
+}
+
+// recordRevision incrementa la version de la promocion y guarda una copia de sus condiciones actuales
+func recordRevision(tx *gorm.DB, promotionID, authorID string, now time.Time) (*models.PromotionRevision, error) {
+	var promotion models.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Schedules").
+		Preload("Segments").
+		First(&promotion, "id = ?", promotionID).Error; err != nil {
+		return nil, errors.New("promotion not found")
+	}
+
+	snapshot, err := revisionSnapshot(&promotion)
+	if err != nil {
+		return nil, errors.New("error al guardar la revision de la promocion")
+	}
+
+	revision := models.PromotionRevision{
+		ID:          uuid.NewString(),
+		PromotionID: promotion.ID,
+		Version:     promotion.Version + 1,
+		Snapshot:    snapshot,
+		AuthorID:    authorID,
+		CreatedAt:   now,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, errors.New("error al guardar la revision de la promocion")
+	}
+	if err := tx.Model(&promotion).UpdateColumn("version", revision.Version).Error; err != nil {
+		return nil, errors.New("error al guardar la revision de la promocion")
+	}
+	return &revision, nil
+}
+
+// currentRevisionID devuelve la revision vigente de la promocion. Las promociones creadas antes de
+// guardar revisiones reciben su primera revision la primera vez que se consumen
+func currentRevisionID(tx *gorm.DB, promotion *models.Promotion, now time.Time) (string, error) {
+	var revision models.PromotionRevision
+	err := tx.Where("promotion_id = ? AND version = ?", promotion.ID, promotion.Version).First(&revision).Error
+	if err == nil {
+		return revision.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	created, err := recordRevision(tx, promotion.ID, "", now)
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// revisionSnapshot serializa las condiciones de la promocion sin los campos que cambian con su uso
+func revisionSnapshot(promotion *models.Promotion) (string, error) {
+	fields, err := promotionFields(promotion)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// promotionFields convierte la promocion en un mapa de campos JSON sin los campos ignorados en las revisiones
+func promotionFields(promotion *models.Promotion) (map[string]interface{}, error) {
+	data, err := json.Marshal(promotion)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, field := range revisionIgnoredFields {
+		delete(fields, field)
+	}
+	return fields, nil
+}
+
+// GetPromotionHistory obtiene las revisiones de una promocion con los cambios de cada una
+func (s *PromotionService) GetPromotionHistory(promotionID string) ([]PromotionRevisionEntry, error) {
+	var promotion models.Promotion
+	if err := s.DB.Unscoped().First(&promotion, "id = ?", promotionID).Error; err != nil {
+		return nil, errors.New("promotion not found")
+	}
+
+	var revisions []models.PromotionRevision
+	if err := s.DB.Where("promotion_id = ?", promotionID).Order("version").Find(&revisions).Error; err != nil {
+		return nil, errors.New("error al obtener el historial de la promocion")
+	}
+
+	history := make([]PromotionRevisionEntry, 0, len(revisions))
+	previous := map[string]interface{}{}
+	for _, revision := range revisions {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(revision.Snapshot), &fields); err != nil {
+			return nil, errors.New("error al obtener el historial de la promocion")
+		}
+
+		history = append(history, PromotionRevisionEntry{
+			PromotionRevision: revision,
+			Snapshot:          json.RawMessage(revision.Snapshot),
+			Changes:           diffFields(previous, fields),
+		})
+		previous = fields
+	}
+	return history, nil
+}
+
+// diffFields compara dos versiones de los campos y devuelve los que han cambiado
+func diffFields(previous, current map[string]interface{}) map[string]RevisionChange {
+	keys := make(map[string]bool)
+	for key := range previous {
+		keys[key] = true
+	}
+	for key := range current {
+		keys[key] = true
+	}
+
+	changes := make(map[string]RevisionChange)
+	for key := range keys {
+		if !reflect.DeepEqual(previous[key], current[key]) {
+			changes[key] = RevisionChange{From: previous[key], To: current[key]}
+		}
+	}
+	return changes
+}
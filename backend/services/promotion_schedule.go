@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const timeOfDayFormat = "15:04"
+
+// Tamaño de la columna de los dias de la franja, suficiente para los siete dias separados por comas
+const maxWeekdaysLength = 13
+
+// validateSchedules normaliza y valida las franjas horarias de una promocion
+func validateSchedules(schedules []models.PromotionSchedule) error {
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		// Normalizamos los dias quitando espacios y repetidos
+		seen := make(map[int]bool)
+		var days []string
+		for _, day := range strings.Split(schedule.Weekdays, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(day))
+			if err != nil || n < 1 || n > 7 {
+				return errors.New("los dias de la franja horaria deben ser numeros del 1 (lunes) al 7 (domingo)")
+			}
+			if !seen[n] {
+				seen[n] = true
+				days = append(days, strconv.Itoa(n))
+			}
+		}
+		schedule.Weekdays = strings.Join(days, ",")
+
+		if len(schedule.Weekdays) > maxWeekdaysLength {
+			return errors.New("los dias de la franja horaria no son validos")
+		}
+
+		// Las horas se guardan como HH:MM para que se puedan comparar como texto en las consultas
+		start, err := parseTimeOfDay(schedule.StartTime)
+		if err != nil {
+			return errors.New("el formato de start_time debe ser HH:MM")
+		}
+		end, err := parseTimeOfDay(schedule.EndTime)
+		if err != nil {
+			return errors.New("el formato de end_time debe ser HH:MM")
+		}
+		schedule.StartTime = start
+		schedule.EndTime = end
+		if schedule.StartTime == schedule.EndTime {
+			return errors.New("la franja horaria no puede empezar y terminar a la misma hora")
+		}
+	}
+	return nil
+}
+
+// parseTimeOfDay valida una hora HH:MM sin superar el tamaño de la columna y la devuelve normalizada
+func parseTimeOfDay(value string) (string, error) {
+	if len(value) > len(timeOfDayFormat) {
+		return "", errors.New("hora no valida")
+	}
+	t, err := time.Parse(timeOfDayFormat, value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(timeOfDayFormat), nil
+}
+
+// replaceSchedules sustituye las franjas horarias guardadas de una promocion
+func replaceSchedules(tx *gorm.DB, promotionID string, schedules []models.PromotionSchedule) error {
+	if err := tx.Where("promotion_id = ?", promotionID).Delete(&models.PromotionSchedule{}).Error; err != nil {
+		return errors.New("error al guardar las franjas horarias")
+	}
+	for i := range schedules {
+		schedules[i].ID = uuid.NewString()
+		schedules[i].PromotionID = promotionID
+	}
+	if len(schedules) > 0 {
+		if err := tx.Create(&schedules).Error; err != nil {
+			return errors.New("error al guardar las franjas horarias")
+		}
+	}
+	return nil
+}
+
+// inSchedule indica si la promocion esta dentro de alguna de sus franjas horarias en el instante indicado
+func inSchedule(schedules []models.PromotionSchedule, now time.Time) bool {
+	if len(schedules) == 0 {
+		return true
+	}
+
+	local := now.In(businessLocation())
+	today := strconv.Itoa(isoWeekday(local))
+	yesterday := strconv.Itoa(isoWeekday(local.AddDate(0, 0, -1)))
+	clock := local.Format(timeOfDayFormat)
+
+	for _, schedule := range schedules {
+		days := "," + schedule.Weekdays + ","
+		if schedule.StartTime < schedule.EndTime {
+			if strings.Contains(days, ","+today+",") && clock >= schedule.StartTime && clock < schedule.EndTime {
+				return true
+			}
+			continue
+		}
+
+		// Franja que cruza la medianoche: empieza hoy o empezo ayer
+		if strings.Contains(days, ","+today+",") && clock >= schedule.StartTime {
+			return true
+		}
+		if strings.Contains(days, ","+yesterday+",") && clock < schedule.EndTime {
+			return true
+		}
+	}
+	return false
+}
+
+// inScheduleScope filtra en SQL las promociones sin franjas o con alguna franja vigente en el instante indicado
+func inScheduleScope(now time.Time) func(*gorm.DB) *gorm.DB {
+	local := now.In(businessLocation())
+	today := "%" + strconv.Itoa(isoWeekday(local)) + "%"
+	yesterday := "%" + strconv.Itoa(isoWeekday(local.AddDate(0, 0, -1))) + "%"
+	clock := local.Format(timeOfDayFormat)
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`NOT EXISTS (SELECT 1 FROM promotion_schedules ps WHERE ps.promotion_id = promotions.id)
+			OR EXISTS (SELECT 1 FROM promotion_schedules ps WHERE ps.promotion_id = promotions.id AND (
+				(ps.start_time < ps.end_time AND ps.weekdays LIKE ? AND ps.start_time <= ? AND ps.end_time > ?)
+				OR (ps.start_time > ps.end_time AND ps.weekdays LIKE ? AND ps.start_time <= ?)
+				OR (ps.start_time > ps.end_time AND ps.weekdays LIKE ? AND ps.end_time > ?)))`,
+			today, clock, clock, today, clock, yesterday, clock)
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PromotionFilter contiene los filtros del listado de promociones para administracion.
+// Los filtros vacios no se aplican
+type PromotionFilter struct {
+	Query    string       // Texto a buscar en el titulo y la descripcion
+	Level    int          // Nivel requerido
+	From     *models.Date // Promociones vigentes en algun momento desde esta fecha
+	To       *models.Date // Promociones vigentes en algun momento hasta esta fecha
+	Statuses []string     // Estados del ciclo de vida
+	StoreID  string       // Promociones dirigidas a segmentos de clientes de la tienda
+	Deleted  string       // Promociones eliminadas: include las incluye y only solo muestra esas, para restaurarlas
+	Sort     string       // Columna por la que se ordena
+	Desc     bool         // Orden descendente
+}
+
+// Valores del filtro de promociones eliminadas
+const (
+	DeletedInclude = "include"
+	DeletedOnly    = "only"
+)
+
+// Columnas por las que se puede ordenar el listado
+var promotionSortColumns = map[string]string{
+	"title":            "title",
+	"level_required":   "level_required",
+	"start_date":       "start_date",
+	"end_date":         "end_date",
+	"status":           "status",
+	"redemption_count": "redemption_count",
+}
+
+var promotionStatuses = []string{
+	PromotionDraft, PromotionInReview, PromotionScheduled, PromotionPublished, PromotionPaused, PromotionArchived,
+}
+
+// SearchPromotions lista todas las promociones (pasadas, futuras y borradores) aplicando busqueda,
+// filtros, orden y paginacion
+func (s *PromotionService) SearchPromotions(filter PromotionFilter, page, pageSize int) ([]models.Promotion, int64, error) {
+
+	// Validamos los filtros
+	for _, status := range filter.Statuses {
+		if !contains(promotionStatuses, status) {
+			return nil, 0, errors.New("el estado " + status + " no es valido")
+		}
+	}
+	if filter.Deleted != "" && filter.Deleted != DeletedInclude && filter.Deleted != DeletedOnly {
+		return nil, 0, errors.New("el filtro deleted debe ser include u only")
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(filter.From.Time) {
+		return nil, 0, errors.New("la fecha hasta no puede ser anterior a la fecha desde")
+	}
+	if filter.Sort == "" {
+		filter.Sort = "start_date"
+		filter.Desc = true
+	}
+	column, ok := promotionSortColumns[filter.Sort]
+	if !ok {
+		return nil, 0, errors.New("no se puede ordenar por " + filter.Sort)
+	}
+
+	// Los filtros se aplican igual al contar y al obtener la pagina
+	filters := func(query *gorm.DB) *gorm.DB {
+		switch filter.Deleted {
+		case DeletedInclude:
+			query = query.Unscoped()
+		case DeletedOnly:
+			query = query.Unscoped().Where("deleted_at IS NOT NULL")
+		}
+		if text := strings.TrimSpace(filter.Query); text != "" {
+			// Busqueda de texto completo en español, y por coincidencia parcial para palabras incompletas
+			like := "%" + escapeLike(text) + "%"
+			query = query.Where(`(to_tsvector('spanish', title || ' ' || coalesce(description, '')) @@ plainto_tsquery('spanish', ?)
+				OR title ILIKE ? OR description ILIKE ?)`, text, like, like)
+		}
+		if filter.Level > 0 {
+			query = query.Where("level_required = ?", filter.Level)
+		}
+		if filter.From != nil {
+			query = query.Where("(end_date IS NULL OR end_date >= ?)", *filter.From)
+		}
+		if filter.To != nil {
+			query = query.Where("start_date <= ?", *filter.To)
+		}
+		if len(filter.Statuses) > 0 {
+			query = query.Where("status IN ?", filter.Statuses)
+		}
+		if filter.StoreID != "" {
+			// Las tiendas del segmento se comparan una a una para que el id no se trate como patron
+			query = query.Where(`EXISTS (SELECT 1 FROM promotion_segments ps JOIN segments sg ON sg.id = ps.segment_id
+				WHERE ps.promotion_id = promotions.id AND ? = ANY(string_to_array(sg.store_ids, ',')))`, filter.StoreID)
+		}
+		return query
+	}
+
+	var total int64
+	if err := s.DB.Model(&models.Promotion{}).Scopes(filters).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	direction := " ASC"
+	if filter.Desc {
+		direction = " DESC"
+	}
+
+	var promotions []models.Promotion
+	err := s.DB.Scopes(filters).
+		Preload("Schedules").
+		Preload("Segments").
+		Preload("Images").
+		Order(column + direction + " NULLS LAST").
+		Order("id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&promotions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return promotions, total, nil
+}
+
+// likeEscaper escapa los comodines de LIKE para buscar el texto de forma literal
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike prepara un texto para usarlo dentro de un patron LIKE con el escape por defecto
+func escapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
@@ -0,0 +1,24 @@
+package services
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"texto sin comodines", "menu del dia", "menu del dia"},
+		{"porcentaje", "50%", `50\%`},
+		{"guion bajo", "happy_hour", `happy\_hour`},
+		{"barra invertida", `a\b`, `a\\b`},
+		{"todos juntos", `\%_`, `\\\%\_`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeLike(tt.text); got != tt.want {
+				t.Errorf("escapeLike(%q) = %q, se esperaba %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
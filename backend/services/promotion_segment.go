@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// replaceSegments sustituye los segmentos a los que va dirigida una promocion comprobando que existen
+func replaceSegments(tx *gorm.DB, promotionID string, segments []models.PromotionSegment) error {
+
+	// Quitamos los segmentos repetidos
+	seen := make(map[string]bool)
+	var unique []models.PromotionSegment
+	for _, segment := range segments {
+		if !seen[segment.SegmentID] {
+			seen[segment.SegmentID] = true
+			unique = append(unique, models.PromotionSegment{PromotionID: promotionID, SegmentID: segment.SegmentID})
+		}
+	}
+
+	ids := make([]string, 0, len(unique))
+	for _, segment := range unique {
+		ids = append(ids, segment.SegmentID)
+	}
+	var found int64
+	if len(ids) > 0 {
+		if err := tx.Model(&models.Segment{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+			return errors.New("error al guardar los segmentos")
+		}
+	}
+	if found != int64(len(ids)) {
+		return errors.New("segmento no encontrado")
+	}
+
+	if err := tx.Where("promotion_id = ?", promotionID).Delete(&models.PromotionSegment{}).Error; err != nil {
+		return errors.New("error al guardar los segmentos")
+	}
+	if len(unique) > 0 {
+		if err := tx.Create(&unique).Error; err != nil {
+			return errors.New("error al guardar los segmentos")
+		}
+	}
+	return nil
+}
+
+// audienceMatcher evalua si un cliente pertenece al publico de las promociones, cargando su
+// perfil y los segmentos una sola vez aunque se evaluen varias promociones
+type audienceMatcher struct {
+	db       *gorm.DB
+	user     *models.User
+	now      time.Time
+	profile  *customerProfile
+	segments map[string]*models.Segment
+}
+
+func newAudienceMatcher(db *gorm.DB, user *models.User, now time.Time) *audienceMatcher {
+	return &audienceMatcher{db: db, user: user, now: now, segments: make(map[string]*models.Segment)}
+}
+
+// matches indica si el cliente pertenece a alguno de los segmentos de la promocion
+func (m *audienceMatcher) matches(promotion *models.Promotion) (bool, error) {
+	if len(promotion.Segments) == 0 {
+		return true, nil
+	}
+
+	if m.profile == nil {
+		profile, err := loadCustomerProfile(m.db, m.user, m.now)
+		if err != nil {
+			return false, err
+		}
+		m.profile = profile
+	}
+
+	// Cargamos los segmentos que todavia no se han leido
+	var missing []string
+	for _, link := range promotion.Segments {
+		if _, ok := m.segments[link.SegmentID]; !ok {
+			missing = append(missing, link.SegmentID)
+		}
+	}
+	if len(missing) > 0 {
+		var segments []models.Segment
+		if err := m.db.Where("id IN ?", missing).Find(&segments).Error; err != nil {
+			return false, err
+		}
+		for i := range segments {
+			m.segments[segments[i].ID] = &segments[i]
+		}
+	}
+
+	for _, link := range promotion.Segments {
+		if segment, ok := m.segments[link.SegmentID]; ok && matchesSegment(segment, m.profile, m.now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -7,53 +7,56 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PromotionService struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Storage ImageStorage // Almacenamiento de las imagenes, para borrar los ficheros al purgar
 }
 
 const dateFormat = "2006-01-02"
 
 // CreatePromotion (logica de negocio para crear una nueva promocion)
-func (s *PromotionService) CreatePromotion(promotion *models.Promotion) error {
+func (s *PromotionService) CreatePromotion(promotion *models.Promotion, authorID string) error {
 
-	// Validar que el titulo esta completo
-	if promotion.Title == "" {
-		return errors.New("el titulo es obligatorio")
+	// Validar los datos de la promocion
+	if err := validatePromotion(promotion); err != nil {
+		return err
 	}
 
-	// Validamos el nivel requerido
-	if promotion.LevelRequired < 1 {
-		return errors.New("el nivel de la promocion tiene que ser mayor que cero")
-	}
+	// Guardar la promocion, sus franjas horarias, sus segmentos y su primera revision en la base de datos
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return insertPromotion(tx, promotion, authorID)
+	})
+}
 
-	// Validar que StartDate siga el formato `YYYY-MM-DD`
-	_, err := time.Parse(dateFormat, promotion.StartDate)
-	if err != nil {
-		return errors.New("el formato de start_date debe ser YYYY-MM-DD")
-	}
+// insertPromotion guarda una promocion ya validada dentro de una transaccion abierta
+func insertPromotion(tx *gorm.DB, promotion *models.Promotion, authorID string) error {
 
-	// Validar que EndDate siga el formato `YYYY-MM-DD` y no sea anterior a StartDate
-	if promotion.EndDate != "" {
-		endDate, err := time.Parse(dateFormat, promotion.EndDate)
-		if err != nil {
-			return errors.New("el formato de end_date debe ser YYYY-MM-DD")
-		}
-
-		startDate, _ := time.Parse(dateFormat, promotion.StartDate)
-		if endDate.Before(startDate) {
-			return errors.New("la fecha de fin no puede ser anterior a la fecha de inicio")
-		}
-	}
-	// Generamos el ID de la promocion
+	// Generamos el ID de la promocion y empezamos sin usos. Toda promocion nueva empieza como
+	// borrador y solo se publica al aprobarla
 	promotion.ID = uuid.NewString()
+	promotion.RedemptionCount = 0
+	promotion.Status = PromotionDraft
+	promotion.StatusChangedAt = nil
+	promotion.StatusChangedBy = ""
+	promotion.Version = 0
 
-	// Guardar la promocion en la base de datos
-	if err := s.DB.Save(promotion).Error; err != nil {
+	if err := tx.Omit(clause.Associations).Save(promotion).Error; err != nil {
 		return errors.New("error al guardar la promocion")
 	}
-
+	if err := replaceSchedules(tx, promotion.ID, promotion.Schedules); err != nil {
+		return err
+	}
+	if err := replaceSegments(tx, promotion.ID, promotion.Segments); err != nil {
+		return err
+	}
+	revision, err := recordRevision(tx, promotion.ID, authorID, time.Now())
+	if err != nil {
+		return err
+	}
+	promotion.Version = revision.Version
 	return nil
 }
 
@@ -63,12 +66,12 @@ func (s *PromotionService) GetActivePromotions(currentDate time.Time, page int,
 	var promotions []models.Promotion
 	var total int64
 
-	// Convertir la fecha actual a `YYYY-MM-DD` para hacer comparaciones de strings
-	currentDateString := currentDate.Format("2006-01-02")
+	// Convertir el instante actual a la fecha de calendario del restaurante
+	today := businessToday(currentDate)
 
-	// Contar el número total de promociones activas
+	// Contar el número total de promociones activas dentro de su franja horaria
 	if err := s.DB.Model(&models.Promotion{}).
-		Where("start_date <= ? AND (end_date IS NULL OR end_date >= ?)", currentDateString, currentDateString).
+		Scopes(activeOnScope(today), inScheduleScope(currentDate)).
 		Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -77,7 +80,11 @@ func (s *PromotionService) GetActivePromotions(currentDate time.Time, page int,
 	offset := (page - 1) * pageSize
 
 	// Consultar las promociones activas con paginación
-	err := s.DB.Where("start_date <= ? AND (end_date IS NULL OR end_date >= ?)", currentDateString, currentDateString).
+	err := s.DB.Scopes(activeOnScope(today), inScheduleScope(currentDate)).
+		Preload("Schedules").
+		Preload("Segments").
+		Preload("Images").
+		Order("start_date, id").
 		Offset(offset).
 		Limit(pageSize).
 		Find(&promotions).Error
@@ -90,12 +97,51 @@ func (s *PromotionService) GetActivePromotions(currentDate time.Time, page int,
 	return promotions, total, nil
 }
 
+// GetActivePromotionsPage obtiene las promociones activas paginadas por cursor (keyset sobre fecha de
+// inicio e id), sin el coste ni las inconsistencias de Offset y Count en listados grandes
+func (s *PromotionService) GetActivePromotionsPage(currentDate time.Time, cursor string, limit int) ([]models.Promotion, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit = cursorLimit(limit)
+
+	today := businessToday(currentDate)
+	query := s.DB.Scopes(activeOnScope(today), inScheduleScope(currentDate))
+	if after != nil {
+		startDate, err := models.ParseDate(after.Value)
+		if err != nil {
+			return nil, "", errors.New("el cursor no es valido")
+		}
+		query = query.Where("(start_date, id) > (?, ?)", startDate, after.ID)
+	}
+
+	// Pedimos un elemento de mas para saber si hay pagina siguiente
+	var promotions []models.Promotion
+	if err := query.Preload("Schedules").
+		Preload("Segments").
+		Preload("Images").
+		Order("start_date, id").
+		Limit(limit + 1).
+		Find(&promotions).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(promotions) > limit {
+		promotions = promotions[:limit]
+		last := promotions[limit-1]
+		next = encodeCursor(last.StartDate.String(), last.ID)
+	}
+	return promotions, next, nil
+}
+
 // GetPromotionByID (maneja la logica de negocio para obtener unua promocion especifica)
 func (s *PromotionService) GetPromotionByID(promotionID string) (*models.Promotion, error) {
 
 	var promotion models.Promotion
 	// Buscamos la promocion en la base de datos y la almacenamos en promotion
-	if err := s.DB.First(&promotion, "id = ?", promotionID).Error; err != nil {
+	if err := s.DB.Preload("Schedules").Preload("Segments").Preload("Images").First(&promotion, "id = ?", promotionID).Error; err != nil {
 		return nil, errors.New("promotion not found")
 	}
 
@@ -104,119 +150,449 @@ func (s *PromotionService) GetPromotionByID(promotionID string) (*models.Promoti
 
 }
 
-// UpdatePromotion (maneja la logica de negocio para actualizar los datos de una promocion existente)
-func (s *PromotionService) UpdatePromotion(id string, updatedPromotion *models.Promotion) error {
-
-	// Validar que el nivel introducido no sea menor que 1
-	// Validar que el titulo esta completo
-	if updatedPromotion.Title == "" {
-		return errors.New("el titulo es obligatorio")
+// GetVisiblePromotionByID obtiene una promocion solo si es visible para los clientes, es decir,
+// programada o publicada. Los borradores, las pausadas y las archivadas se tratan como inexistentes
+func (s *PromotionService) GetVisiblePromotionByID(promotionID string) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := s.DB.Where("status IN ?", livePromotionStatuses).
+		Preload("Schedules").Preload("Segments").Preload("Images").
+		First(&promotion, "id = ?", promotionID).Error; err != nil {
+		return nil, errors.New("promotion not found")
 	}
+	return &promotion, nil
+}
 
-	// Validamos el nivel requerido
-	if updatedPromotion.LevelRequired < 1 {
-		return errors.New("el nivel de la promocion tiene que ser mayor que cero")
-	}
+// Columnas de la promocion que no se pueden modificar al editarla. El estado solo cambia con las
+// acciones del ciclo de vida, la version al guardar la revision y el contador de usos al consumirla
+var protectedPromotionColumns = []string{
+	"id", "status", "status_changed_at", "status_changed_by", "version", "redemption_count", "deleted_at",
+}
 
-	// Validar formato de StartDate
-	_, err := time.Parse(dateFormat, updatedPromotion.StartDate)
-	if err != nil {
-		return errors.New("el formato de start_date debe ser YYYY-MM-DD")
+// UpdatePromotion (maneja la logica de negocio para actualizar los datos de una promocion existente).
+// Solo se actualiza cuando expectedVersion coincide con la version actual
+func (s *PromotionService) UpdatePromotion(id string, updatedPromotion *models.Promotion, expectedVersion int, authorID string) error {
+
+	// Validar los datos de la promocion
+	if err := validatePromotion(updatedPromotion); err != nil {
+		return err
 	}
 
-	// Validar EndDate (si existe) y comprobar que no sea anterior a StartDate
-	if updatedPromotion.EndDate != "" {
-		endDate, err := time.Parse(dateFormat, updatedPromotion.EndDate)
+	// Modificar datos en la base de datos. Las franjas y los segmentos solo se sustituyen si se envian
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// Buscar y bloquear la promocion
+		promotion, err := lockPromotionForEdit(tx, id, expectedVersion)
 		if err != nil {
-			return errors.New("el formato de end_date debe ser YYYY-MM-DD")
+			return err
 		}
 
-		startDate, _ := time.Parse(dateFormat, updatedPromotion.StartDate)
-		if endDate.Before(startDate) {
-			return errors.New("la fecha de fin no puede ser anterior a la fecha de inicio")
+		// Guardamos todas las columnas editables para que los limites y beneficios se puedan vaciar
+		if err := tx.Model(promotion).Select("*").Omit(append([]string{clause.Associations}, protectedPromotionColumns...)...).
+			Updates(updatedPromotion).Error; err != nil {
+			return errors.New("error updating promotion")
 		}
-	}
-	// Buscar y actualizar la promocino
+		if updatedPromotion.Schedules != nil {
+			if err := replaceSchedules(tx, promotion.ID, updatedPromotion.Schedules); err != nil {
+				return err
+			}
+		}
+		if updatedPromotion.Segments != nil {
+			if err := replaceSegments(tx, promotion.ID, updatedPromotion.Segments); err != nil {
+				return err
+			}
+		}
+
+		// Guardamos las nuevas condiciones como una revision
+		revision, err := recordRevision(tx, promotion.ID, authorID, time.Now())
+		if err != nil {
+			return err
+		}
+		updatedPromotion.Version = revision.Version
+		return nil
+	})
+}
+
+// lockPromotionForEdit bloquea la promocion que se va a modificar y comprueba que se puede editar
+// y que nadie la ha modificado desde la version que conoce el cliente
+func lockPromotionForEdit(tx *gorm.DB, id string, expectedVersion int) (*models.Promotion, error) {
 	var promotion models.Promotion
-	if err := s.DB.First(&promotion, "id = ?", id).Error; err != nil {
-		return errors.New("promotion not found")
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Schedules").
+		Preload("Segments").
+		First(&promotion, "id = ?", id).Error; err != nil {
+		return nil, errors.New("promotion not found")
 	}
-
-	// Modificar datos en la base de datos
-	if err := s.DB.Model(&promotion).Updates(updatedPromotion).Error; err != nil {
-		return errors.New("error updating promotion")
+	if promotion.Status == PromotionArchived {
+		return nil, errors.New("no se puede modificar una promocion archivada")
+	}
+	if expectedVersion != promotion.Version {
+		return nil, errors.New("la promocion ha sido modificada por otro usuario")
 	}
+	return &promotion, nil
+}
+
+// DeletePromotion (maneja la logica de negocio para eliminar promociones existentes). El borrado
+// es logico para conservar el historial de usos y poder restaurarla
+func (s *PromotionService) DeletePromotion(id string) error {
 
+	result := s.DB.Delete(&models.Promotion{}, "id = ?", id)
+	if result.Error != nil {
+		return errors.New("error al eliminar la promocion")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("promotion not found")
+	}
 	return nil
 }
 
-// DeletePromotion (maneja la logica de negocio para eliminar promociones existentes)
-func (s *PromotionService) DeletePromotion(id string) error {
+// RestorePromotion recupera una promocion eliminada
+func (s *PromotionService) RestorePromotion(id string) error {
 
-	if err := s.DB.Delete(&models.Promotion{}, "id = ?", id).Error; err != nil {
+	result := s.DB.Unscoped().Model(&models.Promotion{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return errors.New("error al restaurar la promocion")
+	}
+	if result.RowsAffected == 0 {
 		return errors.New("promotion not found")
 	}
 	return nil
 }
 
-// GetActivePromotionsForUser obtiene las promociones activas que el usuario no ha consumido
-func (s *PromotionService) GetActivePromotionsForUser(userID string) ([]models.Promotion, error) {
-	var promotions []models.Promotion
-	currentDate := time.Now().Format(dateFormat)
+// PurgeDeletedPromotions elimina definitivamente las promociones borradas antes del periodo de
+// retencion junto con sus revisiones, imagenes, cupones, vales y tokens de canje. Las que tienen usos
+// registrados o las usan tarjetas de sellos o experimentos se conservan para no romper los informes
+func (s *PromotionService) PurgeDeletedPromotions(now time.Time, retentionDays int) (purged int, kept int, err error) {
+	cutoff := now.AddDate(0, 0, -retentionDays)
+
+	var ids []string
+	if err := s.DB.Unscoped().Model(&models.Promotion{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, 0, errors.New("error al obtener las promociones eliminadas")
+	}
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	// Promociones a las que hacen referencia registros que no pertenecen solo a la promocion
+	references := []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.PromotionUsage{}, "promotion_id"},
+		{&models.StampCard{}, "reward_promotion_id"},
+		{&models.StampReward{}, "promotion_id"},
+		{&models.ExperimentVariant{}, "promotion_id"},
+	}
+	var referenced []string
+	for _, reference := range references {
+		var found []string
+		if err := s.DB.Model(reference.model).Where(reference.column+" IN ?", ids).
+			Distinct().Pluck(reference.column, &found).Error; err != nil {
+			return 0, 0, errors.New("error al comprobar los usos de las promociones")
+		}
+		referenced = append(referenced, found...)
+	}
 
-	var consumedPromotions []string
-	s.DB.Model(&models.PromotionUsage{}).Where("user_id = ?", userID).Pluck("promotion_id", &consumedPromotions)
+	var purgeable []string
+	for _, id := range ids {
+		if !contains(referenced, id) {
+			purgeable = append(purgeable, id)
+		}
+	}
+	if len(purgeable) == 0 {
+		return 0, len(ids), nil
+	}
+
+	// Guardamos las imagenes antes de borrarlas para eliminar despues sus ficheros
+	var images []models.PromotionImage
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("promotion_id IN ?", purgeable).Find(&images).Error; err != nil {
+			return err
+		}
+		owned := []interface{}{
+			&models.PromotionSchedule{},
+			&models.PromotionSegment{},
+			&models.PromotionImage{},
+			&models.PromotionRevision{},
+			&models.CouponCode{},
+			&models.CouponBatch{},
+			&models.Voucher{},
+			&models.RedemptionToken{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("promotion_id IN ?", purgeable).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Where("id IN ?", purgeable).Delete(&models.Promotion{}).Error
+	})
+	if err != nil {
+		return 0, 0, errors.New("error al purgar las promociones")
+	}
+
+	// Los ficheros se borran cuando ya no hay filas que los referencian. Los errores se ignoran
+	// porque un fichero huerfano no afecta a las promociones
+	if s.Storage != nil {
+		for _, img := range images {
+			s.Storage.Delete(img.StorageKey)
+		}
+	}
+
+	return len(purgeable), len(ids) - len(purgeable), nil
+}
+
+// GetActivePromotionsForUser obtiene las promociones vigentes indicando si el usuario puede consumirlas
+// y, si no puede, los motivos. Con eligibleOnly solo se devuelven las que puede consumir.
+// Con recordExposure se registra que al usuario se le ha mostrado la variante de cada experimento,
+// solo debe indicarse cuando el listado lo ve el propio cliente
+func (s *PromotionService) GetActivePromotionsForUser(userID string, eligibleOnly, recordExposure bool) ([]PromotionEligibility, error) {
+	var user models.User
+	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("usuario no encontrado")
+	}
+
+	var promotions []models.Promotion
+	now := time.Now()
+	today := businessToday(now)
 
-	if err := s.DB.Where("start_date <= ? AND (end_date IS NULL OR end_date >= ?)", currentDate, currentDate).
-		Where("id NOT IN ?", consumedPromotions).
+	if err := s.DB.Scopes(activeOnScope(today)).
+		Preload("Schedules").
+		Preload("Segments").
+		Preload("Images").
 		Find(&promotions).Error; err != nil {
 		return nil, err
 	}
-	return promotions, nil
+
+	ids := make([]string, 0, len(promotions))
+	for _, promotion := range promotions {
+		ids = append(ids, promotion.ID)
+	}
+	rules, err := loadEligibilityRules(s.DB, &user, ids, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluamos cada promocion con las mismas reglas que se aplican al consumirla
+	result := []PromotionEligibility{}
+	var exposures []variantAssignment
+	for _, promotion := range promotions {
+		reasons, err := rules.evaluate(&promotion)
+		if err != nil {
+			return nil, err
+		}
+		if eligibleOnly && len(reasons) > 0 {
+			continue
+		}
+
+		// De cada experimento solo se sirve la variante asignada al usuario
+		eligibility := PromotionEligibility{
+			Promotion: promotion,
+			Eligible:  len(reasons) == 0,
+			Reasons:   reasons,
+		}
+		if variant, ok := rules.variants[promotion.ID]; ok {
+			if !variant.assigned {
+				continue
+			}
+			eligibility.Variant = variant.variantName
+			exposures = append(exposures, variant)
+		}
+		result = append(result, eligibility)
+	}
+
+	if recordExposure {
+		if err := recordExposures(s.DB, userID, exposures, now); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
 
 // ConsumePromotion permite a un usuario consumir una promoción si cumple con los requisitos
 func (s *PromotionService) ConsumePromotion(userID, promotionID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := s.ConsumePromotionTx(tx, userID, promotionID)
+		return err
+	})
+}
+
+// ConsumePromotionTx consume la promocion dentro de una transaccion ya abierta y devuelve el uso
+// registrado, para que otros flujos de canje (cupones, QR...) compartan las mismas comprobaciones
+func (s *PromotionService) ConsumePromotionTx(tx *gorm.DB, userID, promotionID string) (*models.PromotionUsage, error) {
 	var promotion models.Promotion
-	if err := s.DB.First(&promotion, "id = ?", promotionID).Error; err != nil {
-		return errors.New("promoción no encontrada")
+	if err := tx.Preload("Schedules").Preload("Segments").First(&promotion, "id = ?", promotionID).Error; err != nil {
+		return nil, errors.New("promoción no encontrada")
 	}
 
-	currentDate := time.Now().Format(dateFormat)
-	if promotion.StartDate > currentDate || (promotion.EndDate != "" && promotion.EndDate < currentDate) {
-		return errors.New("la promoción no está activa en este momento")
+	// Bloqueamos al usuario para que sus consumos simultaneos no superen el limite por usuario
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		return nil, errors.New("usuario no encontrado")
+	}
+
+	// Aplicamos las mismas reglas de elegibilidad que el listado de promociones del usuario
+	now := time.Now()
+	rules, err := loadEligibilityRules(tx, &user, []string{promotionID}, now)
+	if err != nil {
+		return nil, errors.New("error al comprobar la promoción")
+	}
+	reasons, err := rules.evaluate(&promotion)
+	if err != nil {
+		return nil, errors.New("error al comprobar la promoción")
+	}
+	if len(reasons) > 0 {
+		return nil, eligibilityError(&promotion, reasons[0])
+	}
+
+	// Enlazamos el uso con la revision de las condiciones que ha visto el usuario
+	revisionID, err := currentRevisionID(tx, &promotion, now)
+	if err != nil {
+		return nil, errors.New("error al registrar el consumo de la promoción")
+	}
+
+	// Las promociones de recompensa de tarjetas de sellos se canjean contra una recompensa pendiente
+	if rules.stampRewards[promotionID] {
+		return s.consumeStampReward(tx, userID, promotionID, revisionID, now)
+	}
+
+	// Incrementamos el contador global solo si quedan usos disponibles
+	result := tx.Model(&models.Promotion{}).
+		Where("id = ? AND (max_redemptions = 0 OR redemption_count < max_redemptions)", promotionID).
+		UpdateColumn("redemption_count", gorm.Expr("redemption_count + 1"))
+	if result.Error != nil {
+		return nil, errors.New("error al registrar el consumo de la promoción")
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("la promoción se ha agotado")
+	}
+
+	usage := models.PromotionUsage{
+		ID:                  uuid.NewString(),
+		UserID:              userID,
+		PromotionID:         promotionID,
+		ConsumedAt:          now,
+		PromotionRevisionID: revisionID,
+	}
+
+	// Guardamos el movimiento de los puntos extra para poder retirarlos si se anula el consumo
+	if usage.BonusTransactionID, err = s.applyPointsBonus(tx, &promotion, userID, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&usage).Error; err != nil {
+		return nil, errors.New("error al registrar el consumo de la promoción")
+	}
+	return &usage, nil
+}
+
+// applyPointsBonus acredita los puntos extra de las promociones de tipo points_bonus al consumirlas
+// y devuelve el id del movimiento de puntos (vacio si la promocion no da puntos)
+func (s *PromotionService) applyPointsBonus(tx *gorm.DB, promotion *models.Promotion, userID string, now time.Time) (string, error) {
+	if promotion.BenefitType != BenefitPointsBonus || promotion.BonusPoints < 1 {
+		return "", nil
 	}
 
 	var user models.User
-	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
-		return errors.New("usuario no encontrado")
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		return "", errors.New("usuario no encontrado")
 	}
 
-	if user.Level < promotion.LevelRequired {
-		return errors.New("el usuario no tiene el nivel necesario para consumir esta promoción")
+	bonus := models.PointTransaction{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Type:      TransactionBonus,
+		Status:    TransactionCompleted,
+		Points:    promotion.BonusPoints,
+		Reference: promotion.ID,
+		CreatedAt: now,
+	}
+	if err := tx.Create(&bonus).Error; err != nil {
+		return "", errors.New("error al otorgar los puntos extra")
 	}
 
-	var usage models.PromotionUsage
-	if err := s.DB.Where("user_id = ? AND promotion_id = ?", userID, promotionID).First(&usage).Error; err == nil {
-		return errors.New("esta promoción ya ha sido consumida por el usuario")
+	user.Points += promotion.BonusPoints
+	user.Level = CalculateLevel(user.Points)
+	if err := tx.Save(&user).Error; err != nil {
+		return "", errors.New("error al actualizar los puntos del usuario")
+	}
+	return bonus.ID, nil
+}
+
+// CalculateBasketDiscount calcula el descuento de una promocion sobre una cesta antes de consumirla
+func (s *PromotionService) CalculateBasketDiscount(promotionID string, basket Basket) (*BasketDiscount, error) {
+	promotion, err := s.GetVisiblePromotionByID(promotionID)
+	if err != nil {
+		return nil, err
 	}
+	return CalculateDiscount(promotion, basket)
+}
 
-	usage = models.PromotionUsage{
-		ID:          uuid.NewString(),
-		UserID:      userID,
-		PromotionID: promotionID,
-		ConsumedAt:  time.Now(),
+// consumeStampReward canjea la recompensa de sellos pendiente mas antigua del usuario para la promocion
+func (s *PromotionService) consumeStampReward(tx *gorm.DB, userID, promotionID, revisionID string, now time.Time) (*models.PromotionUsage, error) {
+	var reward models.StampReward
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND promotion_id = ? AND redeemed_at IS NULL", userID, promotionID).
+		Order("issued_at").
+		First(&reward).Error; err != nil {
+		return nil, errors.New("el usuario no tiene recompensas de sellos pendientes para esta promoción")
 	}
-	if err := s.DB.Create(&usage).Error; err != nil {
-		return errors.New("error al registrar el consumo de la promoción")
+
+	reward.RedeemedAt = &now
+	if err := tx.Save(&reward).Error; err != nil {
+		return nil, errors.New("error al canjear la recompensa")
 	}
-	return nil
+
+	usage := models.PromotionUsage{
+		ID:                  uuid.NewString(),
+		UserID:              userID,
+		PromotionID:         promotionID,
+		ConsumedAt:          now,
+		PromotionRevisionID: revisionID,
+		StampRewardID:       reward.ID,
+	}
+	if err := tx.Create(&usage).Error; err != nil {
+		return nil, errors.New("error al registrar el consumo de la promoción")
+	}
+	return &usage, nil
+}
+
+// GetUsageHistory obtiene los consumos de promociones del usuario, del mas reciente al mas antiguo, paginados por cursor
+func (s *PromotionService) GetUsageHistory(userID, cursor string, limit int) ([]models.PromotionUsage, string, error) {
+	before, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	limit = cursorLimit(limit)
+
+	query := s.DB.Where("user_id = ?", userID)
+	if before != nil {
+		consumedAt, err := time.Parse(time.RFC3339Nano, before.Value)
+		if err != nil {
+			return nil, "", errors.New("el cursor no es valido")
+		}
+		query = query.Where("(consumed_at, id) < (?, ?)", consumedAt, before.ID)
+	}
+
+	var usages []models.PromotionUsage
+	if err := query.Order("consumed_at DESC, id DESC").Limit(limit + 1).Find(&usages).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(usages) > limit {
+		usages = usages[:limit]
+		last := usages[limit-1]
+		next = encodeCursor(last.ConsumedAt.Format(time.RFC3339Nano), last.ID)
+	}
+	return usages, next, nil
 }
 
 // IsPromotionConsumed verifica si una promoción ha sido consumida por el usuario
 func (s *PromotionService) IsPromotionConsumed(userID, promotionID string) (bool, error) {
 	var usage models.PromotionUsage
-	if err := s.DB.Where("user_id = ? AND promotion_id = ?", userID, promotionID).First(&usage).Error; err != nil {
+	if err := s.DB.Where("user_id = ? AND promotion_id = ? AND voided_at IS NULL", userID, promotionID).First(&usage).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return false, nil
 		}
@@ -224,3 +600,97 @@ func (s *PromotionService) IsPromotionConsumed(userID, promotionID string) (bool
 	}
 	return true, nil
 }
+
+// Periodos del limite de usos por usuario
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+// validateLimits comprueba los limites de uso global y por usuario
+func validateLimits(promotion *models.Promotion) error {
+	if promotion.MaxRedemptions < 0 {
+		return errors.New("el limite total de usos no puede ser negativo")
+	}
+
+	// Por defecto cada usuario puede consumir la promocion una vez
+	if promotion.PerUserLimit == 0 {
+		promotion.PerUserLimit = 1
+	}
+	if promotion.PerUserLimit < 0 {
+		return errors.New("el limite de usos por usuario no puede ser negativo")
+	}
+
+	switch promotion.PerUserPeriod {
+	case "", PeriodDay, PeriodWeek:
+	default:
+		return errors.New("el periodo del limite por usuario debe ser day o week")
+	}
+	return nil
+}
+
+// usagePeriodStart devuelve el inicio del periodo en el que se cuentan los usos por usuario.
+// Los dias y las semanas empiezan a medianoche en la zona horaria del restaurante
+func usagePeriodStart(period string, now time.Time) time.Time {
+	now = now.In(businessLocation())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case PeriodDay:
+		return today
+	case PeriodWeek:
+		// Las semanas empiezan el lunes
+		offset := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -offset)
+	}
+	return time.Time{}
+}
+
+// validatePromotion comprueba los datos de la promocion que se va a crear o modificar
+func validatePromotion(promotion *models.Promotion) error {
+
+	// Validar que el titulo esta completo
+	if promotion.Title == "" {
+		return errors.New("el titulo es obligatorio")
+	}
+
+	// Validamos el nivel requerido
+	if promotion.LevelRequired < 1 {
+		return errors.New("el nivel de la promocion tiene que ser mayor que cero")
+	}
+
+	// Validar que StartDate siga el formato `YYYY-MM-DD`
+	if promotion.StartDate.IsZero() {
+		return errors.New("el formato de start_date debe ser YYYY-MM-DD")
+	}
+
+	// Una EndDate vacia equivale a no tener fecha de fin
+	if promotion.EndDate != nil && promotion.EndDate.IsZero() {
+		promotion.EndDate = nil
+	}
+
+	// Validar que EndDate no sea anterior a StartDate
+	if promotion.EndDate != nil && promotion.EndDate.Before(promotion.StartDate.Time) {
+		return errors.New("la fecha de fin no puede ser anterior a la fecha de inicio")
+	}
+
+	// Validar la definicion del beneficio
+	if err := validateBenefit(promotion); err != nil {
+		return err
+	}
+
+	// Validar las reglas de combinacion
+	if err := validateStacking(promotion); err != nil {
+		return err
+	}
+
+	// Validar los limites de uso
+	if err := validateLimits(promotion); err != nil {
+		return err
+	}
+
+	// Validar las franjas horarias
+	if err := validateSchedules(promotion.Schedules); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxStackingCandidates limita las promociones que se prueban al buscar la mejor combinacion,
+// ya que el numero de combinaciones crece exponencialmente
+const maxStackingCandidates = 10
+
+// AppliedPromotion es el descuento que aporta una promocion dentro de una combinacion
+type AppliedPromotion struct {
+	PromotionID string       `json:"promotion_id"`
+	Title       string       `json:"title"`
+	BenefitType string       `json:"benefit_type"`
+	Discount    float64      `json:"discount"`
+	FreeItems   []BasketItem `json:"free_items,omitempty"`
+	BonusPoints int          `json:"bonus_points,omitempty"`
+}
+
+// BasketCombination es el resultado de aplicar varias promociones compatibles a una misma cesta
+type BasketCombination struct {
+	Subtotal    float64            `json:"subtotal"`
+	Discount    float64            `json:"discount"`
+	Total       float64            `json:"total"`
+	Currency    string             `json:"currency"`
+	BonusPoints int                `json:"bonus_points,omitempty"`
+	Promotions  []AppliedPromotion `json:"promotions"`
+}
+
+// validateStacking comprueba las reglas de combinacion de la promocion
+func validateStacking(promotion *models.Promotion) error {
+	promotion.ExclusivityGroup = strings.TrimSpace(promotion.ExclusivityGroup)
+	if len(promotion.ExclusivityGroup) > 30 {
+		return errors.New("el grupo de exclusividad no puede superar los 30 caracteres")
+	}
+	if promotion.StackingPriority < 0 {
+		return errors.New("la prioridad de combinacion no puede ser negativa")
+	}
+	return nil
+}
+
+// canStack indica si dos promociones se pueden aplicar en la misma compra
+func canStack(a, b *models.Promotion) bool {
+	if a.Exclusive || b.Exclusive {
+		return false
+	}
+	return a.ExclusivityGroup == "" || a.ExclusivityGroup != b.ExclusivityGroup
+}
+
+// checkStacking devuelve un error si alguna pareja de promociones no se puede combinar
+func checkStacking(promotions []models.Promotion) error {
+	for i := range promotions {
+		for j := i + 1; j < len(promotions); j++ {
+			if promotions[i].ID == promotions[j].ID {
+				return errors.New("no se puede aplicar la misma promocion dos veces")
+			}
+			if !canStack(&promotions[i], &promotions[j]) {
+				return errors.New("la promocion " + promotions[i].Title + " no se puede combinar con " + promotions[j].Title)
+			}
+		}
+	}
+	return nil
+}
+
+// sortByStackingPriority ordena las promociones en el orden en que se aplican a la cesta
+func sortByStackingPriority(promotions []models.Promotion) {
+	sort.SliceStable(promotions, func(i, j int) bool {
+		if promotions[i].StackingPriority != promotions[j].StackingPriority {
+			return promotions[i].StackingPriority < promotions[j].StackingPriority
+		}
+		return promotions[i].ID < promotions[j].ID
+	})
+}
+
+// ApplyCombination aplica las promociones a la cesta por orden de prioridad. Los porcentajes se
+// calculan sobre el importe que queda tras los descuentos anteriores y el total nunca es negativo
+func ApplyCombination(promotions []models.Promotion, basket Basket) (*BasketCombination, error) {
+	subtotal, err := basketSubtotal(basket)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := append([]models.Promotion(nil), promotions...)
+	sortByStackingPriority(ordered)
+
+	combination := &BasketCombination{
+		Subtotal:   roundCents(subtotal),
+		Currency:   strings.ToUpper(basket.Currency),
+		Promotions: []AppliedPromotion{},
+	}
+	remaining := subtotal
+	for i := range ordered {
+		// La cesta ya es valida, asi que un error solo indica que la promocion no tiene un beneficio
+		// aplicable a esta cesta. Se incluye sin descuento, como las que se consumian sin beneficio
+		result, err := CalculateDiscount(&ordered[i], basket)
+		if err != nil {
+			result = &BasketDiscount{}
+		}
+
+		discount := result.Discount
+		if ordered[i].BenefitType == BenefitPercentage {
+			discount = remaining * ordered[i].DiscountPercent / 100
+		}
+		discount = roundCents(math.Min(discount, remaining))
+		remaining -= discount
+
+		combination.Discount += discount
+		combination.BonusPoints += result.BonusPoints
+		combination.Promotions = append(combination.Promotions, AppliedPromotion{
+			PromotionID: ordered[i].ID,
+			Title:       ordered[i].Title,
+			BenefitType: ordered[i].BenefitType,
+			Discount:    discount,
+			FreeItems:   result.FreeItems,
+			BonusPoints: result.BonusPoints,
+		})
+	}
+
+	combination.Discount = roundCents(combination.Discount)
+	combination.Total = roundCents(subtotal - combination.Discount)
+	return combination, nil
+}
+
+// BestCombination busca entre las promociones dadas la combinacion compatible con mayor descuento
+// para la cesta. A igual descuento se prefiere la que da mas puntos y despues la de menos promociones
+func BestCombination(promotions []models.Promotion, basket Basket) (*BasketCombination, error) {
+	if _, err := basketSubtotal(basket); err != nil {
+		return nil, err
+	}
+
+	// Descartamos las promociones que no aportan nada a esta cesta
+	type candidate struct {
+		promotion models.Promotion
+		discount  float64
+	}
+	var candidates []candidate
+	for _, promotion := range promotions {
+		result, err := CalculateDiscount(&promotion, basket)
+		if err != nil {
+			continue
+		}
+		if result.Discount > 0 || result.BonusPoints > 0 || len(result.FreeItems) > 0 {
+			candidates = append(candidates, candidate{promotion: promotion, discount: result.Discount})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].discount > candidates[j].discount })
+	if len(candidates) > maxStackingCandidates {
+		candidates = candidates[:maxStackingCandidates]
+	}
+
+	best, err := ApplyCombination(nil, basket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recorremos los subconjuntos compatibles, descartando ramas en cuanto aparece un conflicto
+	var selected []models.Promotion
+	var search func(start int) error
+	search = func(start int) error {
+		if len(selected) > 0 {
+			combination, err := ApplyCombination(selected, basket)
+			if err != nil {
+				return err
+			}
+			if betterCombination(combination, best) {
+				best = combination
+			}
+		}
+		for i := start; i < len(candidates); i++ {
+			compatible := true
+			for j := range selected {
+				if !canStack(&selected[j], &candidates[i].promotion) {
+					compatible = false
+					break
+				}
+			}
+			if !compatible {
+				continue
+			}
+			selected = append(selected, candidates[i].promotion)
+			if err := search(i + 1); err != nil {
+				return err
+			}
+			selected = selected[:len(selected)-1]
+		}
+		return nil
+	}
+	if err := search(0); err != nil {
+		return nil, err
+	}
+	return best, nil
+}
+
+// betterCombination indica si la combinacion a es preferible a la b
+func betterCombination(a, b *BasketCombination) bool {
+	if a.Discount != b.Discount {
+		return a.Discount > b.Discount
+	}
+	if a.BonusPoints != b.BonusPoints {
+		return a.BonusPoints > b.BonusPoints
+	}
+	return len(a.Promotions) < len(b.Promotions)
+}
+
+// basketSubtotal valida las lineas de la cesta y devuelve su importe
+func basketSubtotal(basket Basket) (float64, error) {
+	if len(basket.Items) == 0 {
+		return 0, errors.New("la cesta esta vacia")
+	}
+
+	var subtotal float64
+	for _, item := range basket.Items {
+		if item.Product == "" || item.Quantity < 1 || item.UnitPrice < 0 {
+			return 0, errors.New("las lineas de la cesta no son validas")
+		}
+		subtotal += float64(item.Quantity) * item.UnitPrice
+	}
+	return subtotal, nil
+}
+
+// GetBestCombination devuelve la mejor combinacion de las promociones que el usuario puede consumir ahora
+func (s *PromotionService) GetBestCombination(userID string, basket Basket) (*BasketCombination, error) {
+	// Calcular la combinacion no es mostrar las promociones al cliente, asi que no cuenta como exposicion
+	eligibilities, err := s.GetActivePromotionsForUser(userID, true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	promotions := make([]models.Promotion, 0, len(eligibilities))
+	for _, eligibility := range eligibilities {
+		promotions = append(promotions, eligibility.Promotion)
+	}
+	return BestCombination(promotions, basket)
+}
+
+// ConsumePromotions consume varias promociones en la misma compra, rechazando las combinaciones
+// incompatibles. Si no se indican promociones se consume la mejor combinacion para la cesta
+func (s *PromotionService) ConsumePromotions(userID string, promotionIDs []string, basket *Basket) (*BasketCombination, error) {
+	if len(promotionIDs) == 0 {
+		if basket == nil {
+			return nil, errors.New("indique las promociones a consumir o la cesta de la compra")
+		}
+		best, err := s.GetBestCombination(userID, *basket)
+		if err != nil {
+			return nil, err
+		}
+		if len(best.Promotions) == 0 {
+			return nil, errors.New("ninguna promocion se puede aplicar a la cesta")
+		}
+		for _, applied := range best.Promotions {
+			promotionIDs = append(promotionIDs, applied.PromotionID)
+		}
+	}
+
+	var combination *BasketCombination
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var promotions []models.Promotion
+		if err := tx.Where("id IN ?", promotionIDs).Find(&promotions).Error; err != nil {
+			return errors.New("error al comprobar las promociones")
+		}
+		if len(promotions) != len(promotionIDs) {
+			// Un id repetido tambien da menos filas que ids
+			seen := make(map[string]bool, len(promotionIDs))
+			for _, id := range promotionIDs {
+				if seen[id] {
+					return errors.New("no se puede aplicar la misma promocion dos veces")
+				}
+				seen[id] = true
+			}
+			return errors.New("promoción no encontrada")
+		}
+		if err := checkStacking(promotions); err != nil {
+			return err
+		}
+
+		sortByStackingPriority(promotions)
+		for _, promotion := range promotions {
+			if _, err := s.ConsumePromotionTx(tx, userID, promotion.ID); err != nil {
+				return err
+			}
+		}
+
+		if basket != nil {
+			var err error
+			if combination, err = ApplyCombination(promotions, *basket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return combination, nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"fidelity-client-app/models"
+	"testing"
+)
+
+func TestApplyCombinationWithoutBenefit(t *testing.T) {
+	basket := Basket{Currency: "EUR", Items: []BasketItem{{Product: "menu", Quantity: 2, UnitPrice: 10}}}
+	promotions := []models.Promotion{
+		{ID: "sin-beneficio", Title: "Sin beneficio"},
+		{ID: "moneda", Title: "Otra moneda", BenefitType: BenefitFixedAmount, DiscountAmount: 5, Currency: "USD"},
+		{ID: "porcentaje", Title: "10%", BenefitType: BenefitPercentage, DiscountPercent: 10, StackingPriority: 1},
+	}
+
+	combination, err := ApplyCombination(promotions, basket)
+	if err != nil {
+		t.Fatalf("ApplyCombination() error = %v", err)
+	}
+	if len(combination.Promotions) != 3 {
+		t.Fatalf("se han aplicado %d promociones, se esperaban 3", len(combination.Promotions))
+	}
+	if combination.Discount != 2 || combination.Total != 18 {
+		t.Errorf("descuento %v y total %v, se esperaban 2 y 18", combination.Discount, combination.Total)
+	}
+}
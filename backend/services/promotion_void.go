@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VoidUsage anula un consumo de promocion registrado por error dentro del plazo indicado. El uso
+// no se borra: queda marcado como anulado con su motivo y se devuelven los usos de la promocion,
+// el cupon, el vale o la recompensa de sellos con los que se canjeo y los puntos extra otorgados.
+// Si el cliente ya ha gastado parte de los puntos extra solo se retiran los que le quedan, el saldo
+// nunca queda negativo y la diferencia se guarda en el uso como PointsShortfall
+func (s *PromotionService) VoidUsage(usageID, reason, staffID string, window time.Duration, now time.Time) (*models.PromotionUsage, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("el motivo de la anulacion es obligatorio")
+	}
+	if len(reason) > 250 {
+		return nil, errors.New("el motivo de la anulacion no puede superar los 250 caracteres")
+	}
+
+	var usage models.PromotionUsage
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// Bloqueamos el uso para que no se pueda anular dos veces a la vez
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&usage, "id = ?", usageID).Error; err != nil {
+			return errors.New("consumo no encontrado")
+		}
+		if usage.VoidedAt != nil {
+			return errors.New("el consumo ya ha sido anulado")
+		}
+		if now.Sub(usage.ConsumedAt) > window {
+			return errors.New("el plazo para anular el consumo ha terminado")
+		}
+
+		// Las recompensas de sellos no suman al contador global, vuelven a quedar pendientes
+		if usage.StampRewardID != "" {
+			if err := tx.Model(&models.StampReward{}).Where("id = ?", usage.StampRewardID).
+				Update("redeemed_at", nil).Error; err != nil {
+				return errors.New("error al anular el consumo")
+			}
+		} else if err := tx.Unscoped().Model(&models.Promotion{}).
+			Where("id = ? AND redemption_count > 0", usage.PromotionID).
+			UpdateColumn("redemption_count", gorm.Expr("redemption_count - 1")).Error; err != nil {
+			return errors.New("error al anular el consumo")
+		}
+
+		if usage.CouponCodeID != "" {
+			if err := tx.Model(&models.CouponCode{}).Where("id = ? AND uses > 0", usage.CouponCodeID).
+				UpdateColumn("uses", gorm.Expr("uses - 1")).Error; err != nil {
+				return errors.New("error al anular el consumo")
+			}
+		}
+
+		if usage.VoucherID != "" {
+			if err := reopenVoucher(tx, usage.VoucherID); err != nil {
+				return err
+			}
+		}
+
+		if usage.BonusTransactionID != "" {
+			if err := reversePointsBonus(tx, &usage, now); err != nil {
+				return err
+			}
+		}
+
+		usage.VoidedAt = &now
+		usage.VoidedBy = staffID
+		usage.VoidReason = reason
+		if err := tx.Model(&usage).Select("voided_at", "voided_by", "void_reason", "points_shortfall").Updates(&usage).Error; err != nil {
+			return errors.New("error al anular el consumo")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// reopenVoucher devuelve el vale canjeado con el consumo anulado a su estado anterior. Si ha
+// vencido mientras tanto lo marcara como caducado el proceso periodico
+func reopenVoucher(tx *gorm.DB, voucherID string) error {
+	var voucher models.Voucher
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&voucher, "id = ?", voucherID).Error; err != nil {
+		return errors.New("error al anular el consumo")
+	}
+
+	voucher.Status = VoucherIssued
+	if voucher.ViewedAt != nil {
+		voucher.Status = VoucherViewed
+	}
+	voucher.RedeemedAt = nil
+	voucher.PromotionUsageID = ""
+	if err := tx.Model(&voucher).Select("status", "redeemed_at", "promotion_usage_id").Updates(&voucher).Error; err != nil {
+		return errors.New("error al anular el consumo")
+	}
+	return nil
+}
+
+// reversePointsBonus retira los puntos extra otorgados por el consumo anulado con un movimiento de signo
+// contrario, sin retirar mas puntos de los que tiene el cliente
+func reversePointsBonus(tx *gorm.DB, usage *models.PromotionUsage, now time.Time) error {
+	var bonus models.PointTransaction
+	if err := tx.First(&bonus, "id = ?", usage.BonusTransactionID).Error; err != nil {
+		return errors.New("error al retirar los puntos extra")
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", usage.UserID).Error; err != nil {
+		return errors.New("usuario no encontrado")
+	}
+
+	retired := bonus.Points
+	if retired > user.Points {
+		retired = max(user.Points, 0)
+	}
+	usage.PointsShortfall = bonus.Points - retired
+	if retired == 0 {
+		return nil
+	}
+
+	reversal := models.PointTransaction{
+		ID:        uuid.NewString(),
+		UserID:    usage.UserID,
+		Type:      TransactionReversal,
+		Status:    TransactionCompleted,
+		Points:    -retired,
+		Reference: usage.ID,
+		CreatedAt: now,
+	}
+	if err := tx.Create(&reversal).Error; err != nil {
+		return errors.New("error al retirar los puntos extra")
+	}
+
+	user.Points -= retired
+	user.Level = CalculateLevel(user.Points)
+	if err := tx.Model(&user).Select("points", "level").Updates(&user).Error; err != nil {
+		return errors.New("error al actualizar los puntos del usuario")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/config"
+	"fidelity-client-app/models"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReceiptService struct {
+	DB            *gorm.DB
+	PointsService *PointsService
+}
+
+// Estados de una reclamacion de ticket
+const (
+	ClaimPending  = "pending"
+	ClaimApproved = "approved"
+	ClaimRejected = "rejected"
+)
+
+// SubmitPosTransaction registra un ticket enviado por el TPV de una tienda
+func (s *ReceiptService) SubmitPosTransaction(transaction *models.PosTransaction) error {
+
+	if transaction.StoreID == "" || transaction.ReceiptNumber == "" {
+		return errors.New("la tienda y el numero de ticket son obligatorios")
+	}
+	if _, err := time.Parse(dateFormat, transaction.Date); err != nil {
+		return errors.New("el formato de date debe ser YYYY-MM-DD")
+	}
+	if transaction.Total <= 0 {
+		return errors.New("el total del ticket tiene que ser mayor que cero")
+	}
+
+	// El ticket se registra sin reclamar
+	transaction.ID = uuid.NewString()
+	transaction.ClaimedByUserID = ""
+	transaction.ClaimedAt = nil
+
+	if err := s.DB.Create(transaction).Error; err != nil {
+		return errors.New("el ticket ya ha sido registrado")
+	}
+	return nil
+}
+
+// ClaimReceipt procesa la reclamacion de puntos de un ticket. Si el ticket coincide con uno
+// enviado por el TPV se acreditan los puntos, y si no existe queda pendiente de revision
+func (s *ReceiptService) ClaimReceipt(claim *models.ReceiptClaim) (string, error) {
+
+	// Validamos los datos del ticket
+	if claim.UserID == "" {
+		return "", errors.New("el user id es obligatorio")
+	}
+	if claim.StoreID == "" || claim.ReceiptNumber == "" {
+		return "", errors.New("la tienda y el numero de ticket son obligatorios")
+	}
+	if claim.Total <= 0 {
+		return "", errors.New("el total del ticket tiene que ser mayor que cero")
+	}
+
+	receiptDate, err := models.ParseDate(claim.Date)
+	if err != nil {
+		return "", errors.New("el formato de date debe ser YYYY-MM-DD")
+	}
+
+	// Rechazamos tickets futuros o mas antiguos que la ventana permitida, segun la fecha del restaurante
+	now := time.Now()
+	today := businessToday(now).Time
+	if receiptDate.After(today) {
+		return "", errors.New("la fecha del ticket no puede ser futura")
+	}
+	if receiptDate.Before(today.AddDate(0, 0, -config.Vars.ReceiptClaimWindowDays)) {
+		return "", errors.New("el ticket es demasiado antiguo para reclamar puntos")
+	}
+
+	claim.ID = uuid.NewString()
+	claim.CreatedAt = now
+	claim.Reason = ""
+	claim.ReviewedAt = nil
+
+	var message string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// Comprobamos que el usuario existe
+		var user models.User
+		if err := tx.First(&user, "id = ?", claim.UserID).Error; err != nil {
+			return errors.New("usuario no encontrado")
+		}
+
+		// Rechazamos tickets que ya tienen una reclamacion aprobada o pendiente
+		var existing int64
+		if err := tx.Model(&models.ReceiptClaim{}).
+			Where("store_id = ? AND receipt_number = ? AND status <> ?", claim.StoreID, claim.ReceiptNumber, ClaimRejected).
+			Count(&existing).Error; err != nil {
+			return errors.New("error al comprobar el ticket")
+		}
+		if existing > 0 {
+			return errors.New("el ticket ya ha sido reclamado")
+		}
+
+		// Buscamos el ticket enviado por el TPV
+		var transaction models.PosTransaction
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("store_id = ? AND receipt_number = ?", claim.StoreID, claim.ReceiptNumber).
+			First(&transaction).Error
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("error al comprobar el ticket")
+			}
+
+			// Sin ticket del TPV la reclamacion queda pendiente de revision por el personal
+			claim.Status = ClaimPending
+			if err := tx.Create(claim).Error; err != nil {
+				return errors.New("el ticket ya ha sido reclamado")
+			}
+			message = "El ticket se ha enviado a revision. Los puntos se acreditaran cuando sea aprobado."
+			return nil
+		}
+
+		if transaction.ClaimedByUserID != "" {
+			return errors.New("el ticket ya ha sido reclamado")
+		}
+		if transaction.Date != claim.Date || math.Abs(transaction.Total-claim.Total) > 0.01 {
+			return errors.New("los datos no coinciden con el ticket de la tienda")
+		}
+
+		claim.Status = ClaimApproved
+		claim.ReviewedAt = &now
+		if err := tx.Create(claim).Error; err != nil {
+			return errors.New("el ticket ya ha sido reclamado")
+		}
+
+		message, err = s.creditClaim(tx, claim, &transaction, now)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return message, nil
+}
+
+// GetPendingClaims obtiene las reclamaciones pendientes de revision
+func (s *ReceiptService) GetPendingClaims() ([]models.ReceiptClaim, error) {
+	var claims []models.ReceiptClaim
+	if err := s.DB.Where("status = ?", ClaimPending).Order("created_at").Find(&claims).Error; err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ReviewClaim aprueba o rechaza una reclamacion pendiente. Al aprobarla se acreditan los puntos
+func (s *ReceiptService) ReviewClaim(claimID string, approve bool, reason, reviewerID string) (string, error) {
+	var message string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+
+		var claim models.ReceiptClaim
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claim, "id = ?", claimID).Error; err != nil {
+			return errors.New("reclamacion no encontrada")
+		}
+		if claim.Status != ClaimPending {
+			return errors.New("la reclamacion ya ha sido revisada")
+		}
+		if claim.UserID == reviewerID {
+			return errors.New("no puede revisar sus propias reclamaciones")
+		}
+
+		now := time.Now()
+		claim.ReviewedAt = &now
+		claim.ReviewedBy = reviewerID
+		claim.Reason = reason
+
+		if !approve {
+			claim.Status = ClaimRejected
+			if err := tx.Save(&claim).Error; err != nil {
+				return errors.New("error al guardar la reclamacion")
+			}
+			message = "Reclamacion rechazada"
+			return nil
+		}
+
+		// Si el TPV ha enviado el ticket despues de la reclamacion lo marcamos como reclamado
+		var transaction *models.PosTransaction
+		var found models.PosTransaction
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("store_id = ? AND receipt_number = ?", claim.StoreID, claim.ReceiptNumber).
+			First(&found).Error
+		if err == nil {
+			if found.ClaimedByUserID != "" {
+				return errors.New("el ticket ya ha sido reclamado")
+			}
+			transaction = &found
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("error al comprobar el ticket")
+		}
+
+		claim.Status = ClaimApproved
+		if err := tx.Save(&claim).Error; err != nil {
+			return errors.New("error al guardar la reclamacion")
+		}
+
+		message, err = s.creditClaim(tx, &claim, transaction, now)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return message, nil
+}
+
+// creditClaim marca el ticket del TPV como reclamado y acredita los puntos de la reclamacion
+func (s *ReceiptService) creditClaim(tx *gorm.DB, claim *models.ReceiptClaim, transaction *models.PosTransaction, now time.Time) (string, error) {
+	amount := claim.Total
+	if transaction != nil {
+		transaction.ClaimedByUserID = claim.UserID
+		transaction.ClaimedAt = &now
+		if err := tx.Save(transaction).Error; err != nil {
+			return "", errors.New("error al guardar el ticket")
+		}
+		amount = transaction.Total
+	}
+
+	return s.PointsService.AccumulatePointsTx(tx, PointsAccrual{
+		UserID:         claim.UserID,
+		StoreID:        claim.StoreID,
+		PurchaseAmount: amount,
+		Reference:      claim.ID,
+	})
+}
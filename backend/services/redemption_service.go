@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fidelity-client-app/config"
+	"fidelity-client-app/models"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RedemptionService struct {
+	DB               *gorm.DB
+	PromotionService *PromotionService
+}
+
+// Tipo de token incluido en los claims para no aceptar otros JWT firmados con la misma clave
+const redemptionTokenType = "promotion-redemption"
+
+// Formatos de imagen del codigo QR
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+)
+
+// IssueToken genera un token firmado y de corta duracion para canjear una promocion
+func (s *RedemptionService) IssueToken(userID, promotionID string) (string, time.Time, error) {
+
+	// Comprobamos que la promocion es visible para los clientes y que el usuario existe
+	promotion, err := s.PromotionService.GetVisiblePromotionByID(promotionID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var user models.User
+	if err := s.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return "", time.Time{}, errors.New("usuario no encontrado")
+	}
+
+	// Aplicamos las mismas reglas que al consumirla para no emitir tokens que se rechazaran en caja
+	now := time.Now()
+	rules, err := loadEligibilityRules(s.DB, &user, []string{promotionID}, now)
+	if err != nil {
+		return "", time.Time{}, errors.New("error al comprobar la promoción")
+	}
+	reasons, err := rules.evaluate(promotion)
+	if err != nil {
+		return "", time.Time{}, errors.New("error al comprobar la promoción")
+	}
+	if len(reasons) > 0 {
+		return "", time.Time{}, eligibilityError(promotion, reasons[0])
+	}
+
+	record := models.RedemptionToken{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		PromotionID: promotionID,
+		IssuedAt:    now,
+		ExpiresAt:   now.Add(time.Duration(config.Vars.RedemptionTokenTTLSeconds) * time.Second),
+	}
+
+	// Definimos los claims del token de canje
+	claims := jwt.MapClaims{
+		"jti":          record.ID,
+		"user_id":      userID,
+		"promotion_id": promotionID,
+		"typ":          redemptionTokenType,
+		"iat":          now.Unix(),
+		"exp":          record.ExpiresAt.Unix(),
+		"iss":          "fidelity-client-app",
+	}
+
+	// Firmamos el token
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Vars.JwtKey))
+	if err != nil {
+		return "", time.Time{}, errors.New("error al generar el token de canje")
+	}
+
+	// Guardamos el token para poder garantizar que solo se usa una vez
+	if err := s.DB.Create(&record).Error; err != nil {
+		return "", time.Time{}, errors.New("error al generar el token de canje")
+	}
+
+	return token, record.ExpiresAt, nil
+}
+
+// RenderQR valida el token y lo codifica como un codigo QR en PNG o SVG
+func (s *RedemptionService) RenderQR(token, format string, size int) ([]byte, string, error) {
+	if _, err := parseRedemptionToken(token); err != nil {
+		return nil, "", err
+	}
+
+	qr, err := qrcode.New(token, qrcode.Medium)
+	if err != nil {
+		return nil, "", errors.New("error al generar el codigo QR")
+	}
+
+	switch format {
+	case QRFormatPNG:
+		png, err := qr.PNG(size)
+		if err != nil {
+			return nil, "", errors.New("error al generar el codigo QR")
+		}
+		return png, "image/png", nil
+
+	case QRFormatSVG:
+		return qrSVG(qr.Bitmap(), size), "image/svg+xml", nil
+	}
+
+	return nil, "", errors.New("el formato debe ser png o svg")
+}
+
+// RedeemToken valida el token escaneado por el personal y consume la promocion de forma atomica
+func (s *RedemptionService) RedeemToken(token string) (*models.PromotionUsage, error) {
+	claims, err := parseRedemptionToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage *models.PromotionUsage
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// Bloqueamos el token para que no pueda canjearse dos veces a la vez
+		var record models.RedemptionToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, "id = ?", claims["jti"]).Error; err != nil {
+			return errors.New("token de canje no valido")
+		}
+		if record.UserID != claims["user_id"] || record.PromotionID != claims["promotion_id"] {
+			return errors.New("token de canje no valido")
+		}
+		if record.UsedAt != nil {
+			return errors.New("el token de canje ya ha sido utilizado")
+		}
+
+		now := time.Now()
+		if now.After(record.ExpiresAt) {
+			return errors.New("el token de canje ha caducado")
+		}
+
+		var err error
+		usage, err = s.PromotionService.ConsumePromotionTx(tx, record.UserID, record.PromotionID)
+		if err != nil {
+			return err
+		}
+
+		record.UsedAt = &now
+		if err := tx.Save(&record).Error; err != nil {
+			return errors.New("error al canjear el token")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// parseRedemptionToken comprueba la firma, la caducidad y el tipo del token de canje
+func parseRedemptionToken(token string) (jwt.MapClaims, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("metodo de firma no valido")
+		}
+		return []byte(config.Vars.JwtKey), nil
+	})
+	if err != nil {
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, errors.New("el token de canje ha caducado")
+		}
+		return nil, errors.New("token de canje no valido")
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid || claims["typ"] != redemptionTokenType {
+		return nil, errors.New("token de canje no valido")
+	}
+	return claims, nil
+}
+
+// qrSVG dibuja el mapa de bits del codigo QR como una imagen SVG
+func qrSVG(bitmap [][]bool, size int) []byte {
+	modules := len(bitmap)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/>`, modules, modules)
+	buf.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SegmentService struct {
+	DB *gorm.DB
+}
+
+// CreateSegment valida y guarda un nuevo segmento de clientes
+func (s *SegmentService) CreateSegment(segment *models.Segment) error {
+
+	// Validar que el nombre esta completo
+	if segment.Name == "" {
+		return errors.New("el nombre del segmento es obligatorio")
+	}
+
+	// Validar los rangos de edad y nivel
+	if segment.MinAge < 0 || segment.MaxAge < 0 {
+		return errors.New("la edad del segmento no puede ser negativa")
+	}
+	if segment.MaxAge > 0 && segment.MaxAge < segment.MinAge {
+		return errors.New("la edad maxima no puede ser menor que la minima")
+	}
+	if segment.MinLevel < 0 || segment.MaxLevel < 0 {
+		return errors.New("el nivel del segmento no puede ser negativo")
+	}
+	if segment.MaxLevel > 0 && segment.MaxLevel < segment.MinLevel {
+		return errors.New("el nivel maximo no puede ser menor que el minimo")
+	}
+
+	// Validar las condiciones de actividad
+	if segment.VisitedWithinDays < 0 || segment.InactiveForDays < 0 {
+		return errors.New("los dias de actividad no pueden ser negativos")
+	}
+	if segment.MinTotalSpend < 0 {
+		return errors.New("el gasto minimo no puede ser negativo")
+	}
+
+	// Normalizamos las listas separadas por comas
+	segment.Genders = strings.ToLower(normalizeList(segment.Genders))
+	segment.StoreIDs = normalizeList(segment.StoreIDs)
+
+	segment.ID = uuid.NewString()
+	segment.CreatedAt = time.Now()
+
+	if err := s.DB.Create(segment).Error; err != nil {
+		return errors.New("ya existe un segmento con ese nombre")
+	}
+	return nil
+}
+
+// GetSegments obtiene todos los segmentos guardados
+func (s *SegmentService) GetSegments() ([]models.Segment, error) {
+	var segments []models.Segment
+	if err := s.DB.Order("name").Find(&segments).Error; err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// DeleteSegment elimina un segmento que no este asignado a ninguna promocion, ya que al
+// quitarlo la promocion pasaria a estar dirigida a todos los clientes
+func (s *SegmentService) DeleteSegment(id string) error {
+	var assigned int64
+	if err := s.DB.Model(&models.PromotionSegment{}).Where("segment_id = ?", id).Count(&assigned).Error; err != nil {
+		return errors.New("error al comprobar el segmento")
+	}
+	if assigned > 0 {
+		return errors.New("el segmento esta asignado a promociones")
+	}
+
+	result := s.DB.Delete(&models.Segment{}, "id = ?", id)
+	if result.Error != nil {
+		return errors.New("error al eliminar el segmento")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("segmento no encontrado")
+	}
+	return nil
+}
+
+// customerProfile contiene los datos del cliente sobre los que se evaluan los segmentos
+type customerProfile struct {
+	Gender     string
+	Age        int // -1 si la fecha de nacimiento no es valida
+	Level      int
+	StoreID    string
+	LastVisit  *time.Time
+	TotalSpend float64
+}
+
+// loadCustomerProfile calcula el perfil del cliente a partir de sus datos y sus compras completadas
+func loadCustomerProfile(db *gorm.DB, user *models.User, now time.Time) (*customerProfile, error) {
+	profile := customerProfile{
+		Gender:  strings.ToLower(strings.TrimSpace(user.Gender)),
+		Age:     -1,
+		Level:   user.Level,
+		StoreID: user.StoreID,
+	}
+
+	if birthDate, err := models.ParseDate(user.BirthDate); err == nil {
+		profile.Age = ageOn(birthDate, businessToday(now))
+	}
+
+	var stats struct {
+		TotalSpend float64
+		LastVisit  *time.Time
+	}
+	if err := db.Model(&models.PointTransaction{}).
+		Select("COALESCE(SUM(purchase_amount), 0) AS total_spend, MAX(created_at) AS last_visit").
+		Where("user_id = ? AND type = ? AND status = ?", user.ID, TransactionPurchase, TransactionCompleted).
+		Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	profile.TotalSpend = stats.TotalSpend
+	profile.LastVisit = stats.LastVisit
+
+	return &profile, nil
+}
+
+// ageOn calcula los años cumplidos en la fecha indicada
+func ageOn(birthDate, today models.Date) int {
+	age := today.Year() - birthDate.Year()
+	if today.Month() < birthDate.Month() || (today.Month() == birthDate.Month() && today.Day() < birthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// matchesSegment indica si el perfil del cliente cumple todas las condiciones del segmento
+func matchesSegment(segment *models.Segment, profile *customerProfile, now time.Time) bool {
+	if segment.Genders != "" && !listContains(segment.Genders, profile.Gender) {
+		return false
+	}
+	if segment.MinAge > 0 || segment.MaxAge > 0 {
+		if profile.Age < 0 || profile.Age < segment.MinAge {
+			return false
+		}
+		if segment.MaxAge > 0 && profile.Age > segment.MaxAge {
+			return false
+		}
+	}
+	if profile.Level < segment.MinLevel {
+		return false
+	}
+	if segment.MaxLevel > 0 && profile.Level > segment.MaxLevel {
+		return false
+	}
+	if segment.VisitedWithinDays > 0 {
+		if profile.LastVisit == nil || profile.LastVisit.Before(now.AddDate(0, 0, -segment.VisitedWithinDays)) {
+			return false
+		}
+	}
+	if segment.InactiveForDays > 0 && profile.LastVisit != nil && !profile.LastVisit.Before(now.AddDate(0, 0, -segment.InactiveForDays)) {
+		return false
+	}
+	if profile.TotalSpend < segment.MinTotalSpend {
+		return false
+	}
+	if segment.StoreIDs != "" && !listContains(segment.StoreIDs, profile.StoreID) {
+		return false
+	}
+	return true
+}
+
+// normalizeList quita espacios y elementos vacios o repetidos de una lista separada por comas
+func normalizeList(list string) string {
+	seen := make(map[string]bool)
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// listContains indica si el valor esta en la lista separada por comas
+func listContains(list, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range strings.Split(list, ",") {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
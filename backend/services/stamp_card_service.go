@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StampCardService struct {
+	DB *gorm.DB
+}
+
+// CreateStampCard (logica de negocio para crear un nuevo programa de sellos)
+func (s *StampCardService) CreateStampCard(card *models.StampCard) error {
+
+	// Validar que el titulo esta completo
+	if card.Title == "" {
+		return errors.New("el titulo es obligatorio")
+	}
+
+	// El programa tiene que estar asociado a un producto o a una categoria
+	if card.Product == "" && card.Category == "" {
+		return errors.New("el producto o la categoria son obligatorios")
+	}
+
+	// Validamos el numero de sellos necesarios
+	if card.StampsRequired < 1 {
+		return errors.New("el numero de sellos tiene que ser mayor que cero")
+	}
+
+	if card.ExpirationDays < 0 {
+		return errors.New("los dias de caducidad no pueden ser negativos")
+	}
+
+	// Validar que StartDate siga el formato `YYYY-MM-DD`
+	startDate, err := time.Parse(dateFormat, card.StartDate)
+	if err != nil {
+		return errors.New("el formato de start_date debe ser YYYY-MM-DD")
+	}
+
+	// Validar que EndDate siga el formato `YYYY-MM-DD` y no sea anterior a StartDate
+	if card.EndDate != "" {
+		endDate, err := time.Parse(dateFormat, card.EndDate)
+		if err != nil {
+			return errors.New("el formato de end_date debe ser YYYY-MM-DD")
+		}
+		if endDate.Before(startDate) {
+			return errors.New("la fecha de fin no puede ser anterior a la fecha de inicio")
+		}
+	}
+
+	// Comprobamos que la promocion de recompensa existe
+	var promotion models.Promotion
+	if err := s.DB.First(&promotion, "id = ?", card.RewardPromotionID).Error; err != nil {
+		return errors.New("la promocion de recompensa no existe")
+	}
+
+	// Generamos el ID del programa
+	card.ID = uuid.NewString()
+
+	// Guardar el programa en la base de datos
+	if err := s.DB.Save(card).Error; err != nil {
+		return errors.New("error al guardar la tarjeta de sellos")
+	}
+
+	return nil
+}
+
+// GetActiveStampCards obtiene los programas de sellos activos en la fecha indicada
+func (s *StampCardService) GetActiveStampCards(currentDate time.Time) ([]models.StampCard, error) {
+	var cards []models.StampCard
+	currentDateString := businessToday(currentDate).String()
+
+	if err := s.DB.Where("start_date <= ? AND (end_date IS NULL OR end_date = '' OR end_date >= ?)", currentDateString, currentDateString).
+		Find(&cards).Error; err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+// GetUserStampCards obtiene las tarjetas de un usuario y sus recompensas pendientes de canjear
+func (s *StampCardService) GetUserStampCards(userID string) ([]models.UserStampCard, []models.StampReward, error) {
+	var cards []models.UserStampCard
+	if err := s.DB.Where("user_id = ? AND completed_at IS NULL", userID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Find(&cards).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var rewards []models.StampReward
+	if err := s.DB.Where("user_id = ? AND redeemed_at IS NULL", userID).
+		Find(&rewards).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return cards, rewards, nil
+}
+
+// maxStampsPerCall limita los sellos que el personal puede añadir en una sola compra
+const maxStampsPerCall = 20
+
+// AddStamps sella las tarjetas del usuario que correspondan al producto o categoria comprados
+// y emite automaticamente las recompensas de las tarjetas que se completen
+func (s *StampCardService) AddStamps(userID, product, category string, quantity int) ([]models.UserStampCard, []models.StampReward, error) {
+
+	if product == "" && category == "" {
+		return nil, nil, errors.New("el producto o la categoria son obligatorios")
+	}
+	if quantity < 1 || quantity > maxStampsPerCall {
+		return nil, nil, errors.New("la cantidad tiene que estar entre 1 y " + strconv.Itoa(maxStampsPerCall))
+	}
+
+	var updatedCards []models.UserStampCard
+	var issuedRewards []models.StampReward
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// Bloqueamos el usuario para que dos sellados a la vez no abran o completen la misma tarjeta
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return errors.New("usuario no encontrado")
+		}
+
+		// Buscamos los programas activos que coinciden con el producto o la categoria
+		now := time.Now()
+		currentDate := businessToday(now).String()
+
+		var programs []models.StampCard
+		if err := tx.Where("start_date <= ? AND (end_date IS NULL OR end_date = '' OR end_date >= ?)", currentDate, currentDate).
+			Where("(product <> '' AND product = ?) OR (category <> '' AND category = ?)", product, category).
+			Find(&programs).Error; err != nil {
+			return errors.New("error al obtener las tarjetas de sellos")
+		}
+
+		if len(programs) == 0 {
+			return errors.New("no hay tarjetas de sellos activas para este producto")
+		}
+
+		for _, program := range programs {
+
+			// Buscamos la tarjeta abierta y no caducada del usuario o empezamos una nueva
+			var card models.UserStampCard
+			err := tx.Where("user_id = ? AND stamp_card_id = ? AND completed_at IS NULL", userID, program.ID).
+				Where("expires_at IS NULL OR expires_at > ?", now).
+				First(&card).Error
+			if err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return errors.New("error al obtener la tarjeta de sellos")
+				}
+				card = newUserStampCard(userID, program, now)
+			}
+
+			card.Stamps += quantity
+
+			// Completamos tantas tarjetas como permitan los sellos, pasando el sobrante a una nueva
+			for card.Stamps >= program.StampsRequired {
+				leftover := card.Stamps - program.StampsRequired
+				completedAt := now
+				card.Stamps = program.StampsRequired
+				card.CompletedAt = &completedAt
+
+				if err := tx.Save(&card).Error; err != nil {
+					return errors.New("error al guardar la tarjeta de sellos")
+				}
+
+				reward := models.StampReward{
+					ID:              uuid.NewString(),
+					UserID:          userID,
+					StampCardID:     program.ID,
+					UserStampCardID: card.ID,
+					PromotionID:     program.RewardPromotionID,
+					IssuedAt:        now,
+				}
+				if err := tx.Create(&reward).Error; err != nil {
+					return errors.New("error al emitir la recompensa")
+				}
+				issuedRewards = append(issuedRewards, reward)
+
+				card = newUserStampCard(userID, program, now)
+				card.Stamps = leftover
+			}
+
+			// Si la tarjeta se ha completado sin sobrante no guardamos una tarjeta vacia
+			if card.Stamps == 0 {
+				continue
+			}
+
+			if err := tx.Save(&card).Error; err != nil {
+				return errors.New("error al guardar la tarjeta de sellos")
+			}
+			updatedCards = append(updatedCards, card)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return updatedCards, issuedRewards, nil
+}
+
+// newUserStampCard inicializa una tarjeta vacia aplicando la caducidad del programa
+func newUserStampCard(userID string, program models.StampCard, now time.Time) models.UserStampCard {
+	card := models.UserStampCard{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		StampCardID: program.ID,
+		StartedAt:   now,
+	}
+	if program.ExpirationDays > 0 {
+		expiresAt := now.AddDate(0, 0, program.ExpirationDays)
+		card.ExpiresAt = &expiresAt
+	}
+	return card
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"fidelity-client-app/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Estados de un vale personal
+const (
+	VoucherIssued   = "issued"
+	VoucherViewed   = "viewed"
+	VoucherRedeemed = "redeemed"
+	VoucherExpired  = "expired"
+)
+
+// Estados en los que el vale todavia se puede canjear
+var pendingVoucherStatuses = []string{VoucherIssued, VoucherViewed}
+
+type VoucherService struct {
+	DB               *gorm.DB
+	PromotionService *PromotionService
+}
+
+// IssueVoucher emite un vale personal de la promocion para el usuario
+func (s *VoucherService) IssueVoucher(voucher *models.Voucher, now time.Time) error {
+	voucher.Reason = strings.TrimSpace(voucher.Reason)
+	if len(voucher.Reason) > 100 {
+		return errors.New("el motivo no puede superar los 100 caracteres")
+	}
+	if !voucher.ExpiresAt.After(now) {
+		return errors.New("la fecha de caducidad tiene que ser posterior a la fecha actual")
+	}
+
+	var promotion models.Promotion
+	if err := s.DB.First(&promotion, "id = ?", voucher.PromotionID).Error; err != nil {
+		return errors.New("promotion not found")
+	}
+	// Solo se emiten vales de promociones aprobadas que no han terminado
+	if !contains(livePromotionStatuses, promotion.Status) {
+		return errors.New("solo se pueden emitir vales de promociones programadas o publicadas")
+	}
+	if promotion.EndDate != nil && promotion.EndDate.Before(businessToday(now).Time) {
+		return errors.New("no se pueden emitir vales de una promocion terminada")
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, "id = ?", voucher.UserID).Error; err != nil {
+		return errors.New("usuario no encontrado")
+	}
+
+	voucher.ID = uuid.NewString()
+	voucher.Status = VoucherIssued
+	voucher.IssuedAt = now
+	voucher.ViewedAt = nil
+	voucher.RedeemedAt = nil
+	voucher.PromotionUsageID = ""
+	voucher.Promotion = nil
+	if err := s.DB.Create(voucher).Error; err != nil {
+		return errors.New("error al emitir el vale")
+	}
+	return nil
+}
+
+// GetWallet devuelve los vales del usuario con su promocion, los pendientes primero y por caducidad.
+// Antes marca como caducados los vales vencidos
+func (s *VoucherService) GetWallet(userID string, status string, now time.Time) ([]models.Voucher, error) {
+	if status != "" && !contains([]string{VoucherIssued, VoucherViewed, VoucherRedeemed, VoucherExpired}, status) {
+		return nil, errors.New("el estado no es valido")
+	}
+	if err := s.expireVouchers(s.DB.Where("user_id = ?", userID), now); err != nil {
+		return nil, err
+	}
+
+	query := s.DB.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var vouchers []models.Voucher
+	if err := query.
+		Preload("Promotion").
+		Preload("Promotion.Images").
+		Order("CASE WHEN status IN ('issued', 'viewed') THEN 0 ELSE 1 END, expires_at, id").
+		Find(&vouchers).Error; err != nil {
+		return nil, errors.New("error al obtener los vales")
+	}
+	return vouchers, nil
+}
+
+// ViewVoucher marca el vale como visto por el usuario
+func (s *VoucherService) ViewVoucher(userID, voucherID string, now time.Time) (*models.Voucher, error) {
+	var voucher models.Voucher
+	if err := s.DB.First(&voucher, "id = ? AND user_id = ?", voucherID, userID).Error; err != nil {
+		return nil, errors.New("vale no encontrado")
+	}
+
+	// Solo pasa a visto la primera vez; los vales canjeados o caducados no cambian
+	if voucher.Status == VoucherIssued && voucher.ExpiresAt.After(now) {
+		voucher.Status = VoucherViewed
+		voucher.ViewedAt = &now
+		if err := s.DB.Model(&voucher).Select("status", "viewed_at").Updates(&voucher).Error; err != nil {
+			return nil, errors.New("error al actualizar el vale")
+		}
+	}
+	return &voucher, nil
+}
+
+// RedeemVoucher canjea el vale consumiendo su promocion. El vale es personal, asi que solo se
+// comprueban su caducidad y que no se haya canjeado, no la visibilidad publica de la promocion
+// ni su limite de usos por usuario
+func (s *VoucherService) RedeemVoucher(userID, voucherID string, now time.Time) (*models.PromotionUsage, error) {
+	var usage *models.PromotionUsage
+	var expired bool
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+
+		// Bloqueamos el vale para que no se pueda canjear dos veces a la vez
+		var voucher models.Voucher
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&voucher, "id = ? AND user_id = ?", voucherID, userID).Error; err != nil {
+			return errors.New("vale no encontrado")
+		}
+		switch {
+		case voucher.Status == VoucherRedeemed:
+			return errors.New("el vale ya ha sido canjeado")
+		case voucher.Status == VoucherExpired || !voucher.ExpiresAt.After(now):
+			expired = true
+			return errors.New("el vale ha caducado")
+		}
+
+		var err error
+		usage, err = s.consumeVoucherPromotion(tx, &voucher, now)
+		if err != nil {
+			return err
+		}
+
+		voucher.Status = VoucherRedeemed
+		voucher.RedeemedAt = &now
+		voucher.PromotionUsageID = usage.ID
+		if err := tx.Model(&voucher).Select("status", "redeemed_at", "promotion_usage_id").Updates(&voucher).Error; err != nil {
+			return errors.New("error al canjear el vale")
+		}
+		return nil
+	})
+
+	// El vale vencido se marca como caducado fuera de la transaccion que se ha deshecho
+	if expired {
+		s.expireVouchers(s.DB.Where("id = ?", voucherID), now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// consumeVoucherPromotion registra el uso de la promocion del vale. El uso cuenta en el contador de
+// la promocion pero no lo limita, porque los vales se emiten uno a uno a clientes concretos
+func (s *VoucherService) consumeVoucherPromotion(tx *gorm.DB, voucher *models.Voucher, now time.Time) (*models.PromotionUsage, error) {
+	var promotion models.Promotion
+	if err := tx.First(&promotion, "id = ?", voucher.PromotionID).Error; err != nil {
+		return nil, errors.New("promoción no encontrada")
+	}
+	if promotion.Status == PromotionArchived {
+		return nil, errors.New("la promocion del vale ha sido archivada")
+	}
+
+	// Enlazamos el uso con la revision de las condiciones vigentes al canjear el vale
+	revisionID, err := currentRevisionID(tx, &promotion, now)
+	if err != nil {
+		return nil, errors.New("error al canjear el vale")
+	}
+	if err := tx.Model(&models.Promotion{}).Where("id = ?", promotion.ID).
+		UpdateColumn("redemption_count", gorm.Expr("redemption_count + 1")).Error; err != nil {
+		return nil, errors.New("error al canjear el vale")
+	}
+
+	usage := models.PromotionUsage{
+		ID:                  uuid.NewString(),
+		UserID:              voucher.UserID,
+		PromotionID:         promotion.ID,
+		ConsumedAt:          now,
+		PromotionRevisionID: revisionID,
+		VoucherID:           voucher.ID,
+	}
+	if usage.BonusTransactionID, err = s.PromotionService.applyPointsBonus(tx, &promotion, voucher.UserID, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&usage).Error; err != nil {
+		return nil, errors.New("error al canjear el vale")
+	}
+	return &usage, nil
+}
+
+// ExpireVouchers marca como caducados todos los vales pendientes vencidos
+func (s *VoucherService) ExpireVouchers(now time.Time) error {
+	return s.expireVouchers(s.DB, now)
+}
+
+// expireVouchers marca como caducados los vales pendientes vencidos de la consulta
+func (s *VoucherService) expireVouchers(query *gorm.DB, now time.Time) error {
+	if err := query.Model(&models.Voucher{}).
+		Where("status IN ? AND expires_at <= ?", pendingVoucherStatuses, now).
+		Update("status", VoucherExpired).Error; err != nil {
+		return errors.New("error al actualizar los vales caducados")
+	}
+	return nil
+}